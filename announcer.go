@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"github.com/jaredkotoff/meetup-bot/meetup"
+	"log"
+	"time"
+)
+
+// pollPageSize is how many upcoming events are tracked per group
+const pollPageSize = 25
+
+// startPoller checks every guild's group for new events once immediately and
+// then every interval until ctx is cancelled
+func (b *Bot) startPoller(ctx context.Context, s Session, interval time.Duration) {
+	if !b.Work.Start() {
+		return
+	}
+	go func() {
+		defer b.Work.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			b.pollGuilds(ctx, s)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// pollGuilds runs a single poll for every guild the bot has settings for,
+// stopping early if ctx is cancelled
+func (b *Bot) pollGuilds(ctx context.Context, s Session) {
+	guilds, err := b.Store.Guilds()
+	if err != nil {
+		log.Printf("Error listing guilds: %s\n", err.Error())
+		return
+	}
+	for _, guildID := range guilds {
+		if ctx.Err() != nil {
+			return
+		}
+		start := time.Now()
+		err := b.pollGuild(ctx, s, guildID)
+		pollDuration.Observe(time.Since(start))
+		if err != nil && ctx.Err() == nil {
+			b.Status.recordError("poll")
+			log.Printf("Error polling guild %v: %s\n", guildID, err.Error())
+		}
+	}
+}
+
+// pollGuild fetches the upcoming events of each of the guild's groups and
+// announces any that have not been seen before, along with changes to and
+// cancellations of ones that have. The first poll after a group is added only
+// records its existing events so the channel is not flooded. Groups without
+// an announcement channel are still tracked for reminders when the guild has
+// a reminders channel.
+func (b *Bot) pollGuild(ctx context.Context, s Session, guildID string) error {
+	groups, err := b.getGroups(guildID)
+	if err != nil || len(groups) == 0 {
+		return err
+	}
+	channelID, err := b.Store.Setting(guildID, announceChannelKey)
+	if err != nil {
+		return err
+	}
+	routes, err := b.getGroupChannels(guildID)
+	if err != nil {
+		return err
+	}
+	reminderChannelID := b.guildChannel(guildID, reminderChannelKey)
+	fields, err := b.getWatchedFields(guildID)
+	if err != nil {
+		return err
+	}
+
+	var seeded []string
+	if err := b.getGuildJSON(guildID, seededGroupsKey, &seeded); err != nil {
+		return err
+	}
+	tracked, err := b.Store.TrackedEvents(guildID)
+	if err != nil {
+		return err
+	}
+	ts := b.getTimeSettings(guildID)
+
+	current := make(map[string]meetup.Event)
+	for _, group := range groups {
+		target := groupChannel(routes, string(channelID), group)
+		if target == "" && reminderChannelID == "" {
+			continue
+		}
+
+		events, err := b.groupEvents(ctx, group)
+		if err != nil && ctx.Err() != nil {
+			// Shutting down, which isn't worth reporting
+			return nil
+		}
+		if err != nil {
+			b.Status.recordError("meetup")
+			log.Printf("Error polling %v: %s\n", group, err.Error())
+			b.guildLog(s, guildID, "Couldn't check `%v` for new events: %s", group, err.Error())
+			// Keep what we knew about the group until it can be fetched
+			for id, event := range tracked {
+				if event.Group.URLName == group {
+					current[id] = event
+				}
+			}
+			continue
+		}
+		labelEvents(events, group)
+		b.Status.recordPoll(group, time.Now())
+
+		for _, event := range events {
+			current[event.ID] = event
+			if target == "" || !containsString(seeded, group) || event.Visibility != "public" {
+				continue
+			}
+
+			msg := ""
+			old, seen := tracked[event.ID]
+			if !seen && event.Status == "upcoming" {
+				data := eventData(event, ts)
+				data.ShowGroup = len(groups) > 1
+				msg = b.renderEvent(guildID, "announce", data)
+			} else if seen {
+				msg = changeNotice(old, event, fields, ts)
+			}
+			if msg == "" {
+				continue
+			}
+
+			if err := sendMessage(s, target, msg); err != nil {
+				// Keep the last announced version so the next poll tries again
+				b.Status.recordError("announce")
+				log.Printf("Error announcing event %v: %s\n", event.ID, err.Error())
+				b.guildLog(s, guildID, "Couldn't announce `%v` in <#%v>: %s", event.Name, target, err.Error())
+				if seen {
+					current[event.ID] = old
+				} else {
+					delete(current, event.ID)
+				}
+				continue
+			}
+			b.Stats.Count(statAnnounced)
+			switch {
+			case !seen:
+				announcementsTotal.Inc("new")
+			case event.Status == "cancelled":
+				announcementsTotal.Inc("cancelled")
+			default:
+				announcementsTotal.Inc("changed")
+			}
+		}
+
+		if !containsString(seeded, group) {
+			seeded = append(seeded, group)
+		}
+	}
+
+	if err := b.putGuildJSON(guildID, seededGroupsKey, seeded); err != nil {
+		return err
+	}
+	return b.Store.PutTrackedEvents(guildID, current)
+}
+
+// groupChannel returns the channel a group's posts go to: its own channel if
+// it has one, otherwise the guild's announcement channel
+func groupChannel(routes map[string]string, channelID, group string) string {
+	if routed, ok := routes[group]; ok {
+		return routed
+	}
+	return channelID
+}
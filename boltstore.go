@@ -0,0 +1,327 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/boltdb/bolt"
+	"github.com/jaredkotoff/meetup-bot/meetup"
+)
+
+const (
+	// guildsBucket is the top-level bucket holding a bucket per guild
+	guildsBucket = "guilds"
+	// archiveBucket is the top-level bucket the buckets of guilds the bot has
+	// left are kept in, so they can be restored if it is added back
+	archiveBucket = "archive"
+	// settingsBucket holds a guild's settings, one key per setting
+	settingsBucket = "settings"
+	// eventsBucket holds the last seen version of each tracked event, keyed
+	// by event ID
+	eventsBucket = "events"
+	// remindersBucket holds the JSON list of reminder offsets already sent
+	// for each event, keyed by event ID
+	remindersBucket = "reminders"
+	// historyBucket holds records of what the bot has done in a guild, such
+	// as the last next event it found
+	historyBucket = "history"
+)
+
+// guildBuckets are the buckets nested in every guild's bucket
+var guildBuckets = []string{settingsBucket, eventsBucket, remindersBucket, historyBucket}
+
+// boltStore keeps guild state in a bolt db laid out as described by the
+// bucket constants above
+type boltStore struct {
+	db *bolt.DB
+}
+
+// newBoltStore returns a Store backed by db, which must already be migrated
+func newBoltStore(db *bolt.DB) *boltStore {
+	return &boltStore{db: db}
+}
+
+// createGuildBucket creates a guild's bucket and its nested buckets under
+// parent, keeping any that already exist
+func createGuildBucket(parent *bolt.Bucket, guildID string) (*bolt.Bucket, error) {
+	b, err := parent.CreateBucketIfNotExists([]byte(guildID))
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range guildBuckets {
+		if _, err := b.CreateBucketIfNotExists([]byte(name)); err != nil {
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+// guildBucket returns one of a guild's nested buckets
+func guildBucket(tx *bolt.Tx, guildID, name string) (*bolt.Bucket, error) {
+	guilds := tx.Bucket([]byte(guildsBucket))
+	if guilds == nil || guilds.Bucket([]byte(guildID)) == nil {
+		return nil, noGuildError(guildID)
+	}
+	b := guilds.Bucket([]byte(guildID)).Bucket([]byte(name))
+	if b == nil {
+		return nil, fmt.Errorf("no %v bucket for guild %v", name, guildID)
+	}
+	return b, nil
+}
+
+// copyBucket copies every key and nested bucket of src into dst
+func copyBucket(dst, src *bolt.Bucket) error {
+	return src.ForEach(func(k, v []byte) error {
+		// Nested buckets have nil values
+		if v != nil {
+			return dst.Put(k, v)
+		}
+		nested, err := dst.CreateBucketIfNotExists(k)
+		if err != nil {
+			return err
+		}
+		return copyBucket(nested, src.Bucket(k))
+	})
+}
+
+// Guilds returns the ID of every guild with a bucket
+func (bs *boltStore) Guilds() ([]string, error) {
+	var ids []string
+	err := bs.db.View(func(tx *bolt.Tx) error {
+		guilds := tx.Bucket([]byte(guildsBucket))
+		if guilds == nil {
+			return nil
+		}
+		return guilds.ForEach(func(k, v []byte) error {
+			if v == nil {
+				ids = append(ids, string(k))
+			}
+			return nil
+		})
+	})
+	return ids, err
+}
+
+// EnsureGuild creates a guild's bucket if it doesn't exist, moving it back
+// from the archive when there is one
+func (bs *boltStore) EnsureGuild(guildID string) (bool, error) {
+	restored := false
+	err := bs.db.Update(func(tx *bolt.Tx) error {
+		guilds, err := tx.CreateBucketIfNotExists([]byte(guildsBucket))
+		if err != nil {
+			return err
+		}
+		exists := guilds.Bucket([]byte(guildID)) != nil
+		b, err := createGuildBucket(guilds, guildID)
+		if err != nil || exists {
+			return err
+		}
+
+		archive := tx.Bucket([]byte(archiveBucket))
+		if archive == nil || archive.Bucket([]byte(guildID)) == nil {
+			return nil
+		}
+		if err := copyBucket(b, archive.Bucket([]byte(guildID))); err != nil {
+			return err
+		}
+		restored = true
+		return archive.DeleteBucket([]byte(guildID))
+	})
+	return restored, err
+}
+
+// ArchiveGuild moves a guild's bucket into the archive bucket
+func (bs *boltStore) ArchiveGuild(guildID string) error {
+	return bs.db.Update(func(tx *bolt.Tx) error {
+		guilds := tx.Bucket([]byte(guildsBucket))
+		if guilds == nil || guilds.Bucket([]byte(guildID)) == nil {
+			return nil
+		}
+		archive, err := tx.CreateBucketIfNotExists([]byte(archiveBucket))
+		if err != nil {
+			return err
+		}
+		if archive.Bucket([]byte(guildID)) != nil {
+			if err := archive.DeleteBucket([]byte(guildID)); err != nil {
+				return err
+			}
+		}
+		archived, err := archive.CreateBucket([]byte(guildID))
+		if err != nil {
+			return err
+		}
+		if err := copyBucket(archived, guilds.Bucket([]byte(guildID))); err != nil {
+			return err
+		}
+		return guilds.DeleteBucket([]byte(guildID))
+	})
+}
+
+// PurgeGuild deletes a guild's bucket and any archived copy
+func (bs *boltStore) PurgeGuild(guildID string) error {
+	return bs.db.Update(func(tx *bolt.Tx) error {
+		for _, name := range []string{guildsBucket, archiveBucket} {
+			parent := tx.Bucket([]byte(name))
+			if parent == nil || parent.Bucket([]byte(guildID)) == nil {
+				continue
+			}
+			if err := parent.DeleteBucket([]byte(guildID)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Setting reads a single key from the guild's settings bucket
+func (bs *boltStore) Setting(guildID, key string) ([]byte, error) {
+	var v []byte
+	err := bs.db.View(func(tx *bolt.Tx) error {
+		b, err := guildBucket(tx, guildID, settingsBucket)
+		if err != nil {
+			return err
+		}
+		// Copy the value since it is only valid during the transaction
+		v = append([]byte(nil), b.Get([]byte(key))...)
+		return nil
+	})
+	return v, err
+}
+
+// Settings reads every key of the guild's settings bucket
+func (bs *boltStore) Settings(guildID string) (map[string][]byte, error) {
+	settings := make(map[string][]byte)
+	err := bs.db.View(func(tx *bolt.Tx) error {
+		b, err := guildBucket(tx, guildID, settingsBucket)
+		if err != nil {
+			return err
+		}
+		return b.ForEach(func(k, v []byte) error {
+			settings[string(k)] = append([]byte(nil), v...)
+			return nil
+		})
+	})
+	return settings, err
+}
+
+// PutSetting writes a single key to the guild's settings bucket
+func (bs *boltStore) PutSetting(guildID, key string, value []byte) error {
+	return bs.db.Update(func(tx *bolt.Tx) error {
+		b, err := guildBucket(tx, guildID, settingsBucket)
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte(key), value)
+	})
+}
+
+// DeleteSetting removes a key from the guild's settings bucket
+func (bs *boltStore) DeleteSetting(guildID, key string) error {
+	return bs.db.Update(func(tx *bolt.Tx) error {
+		b, err := guildBucket(tx, guildID, settingsBucket)
+		if err != nil {
+			return err
+		}
+		return b.Delete([]byte(key))
+	})
+}
+
+// TrackedEvents decodes the guild's events bucket
+func (bs *boltStore) TrackedEvents(guildID string) (map[string]meetup.Event, error) {
+	events := make(map[string]meetup.Event)
+	err := bs.db.View(func(tx *bolt.Tx) error {
+		b, err := guildBucket(tx, guildID, eventsBucket)
+		if err != nil {
+			return err
+		}
+		return b.ForEach(func(k, v []byte) error {
+			var event meetup.Event
+			if err := json.Unmarshal(v, &event); err != nil {
+				return err
+			}
+			events[string(k)] = event
+			return nil
+		})
+	})
+	return events, err
+}
+
+// PutTrackedEvents replaces the contents of the guild's events bucket
+func (bs *boltStore) PutTrackedEvents(guildID string, events map[string]meetup.Event) error {
+	values := make(map[string]interface{})
+	for id, event := range events {
+		values[id] = event
+	}
+	return bs.replaceBucket(guildID, eventsBucket, values)
+}
+
+// SentReminders decodes the guild's reminders bucket
+func (bs *boltStore) SentReminders(guildID string) (map[string][]string, error) {
+	sent := make(map[string][]string)
+	err := bs.db.View(func(tx *bolt.Tx) error {
+		b, err := guildBucket(tx, guildID, remindersBucket)
+		if err != nil {
+			return err
+		}
+		return b.ForEach(func(k, v []byte) error {
+			var offsets []string
+			if err := json.Unmarshal(v, &offsets); err != nil {
+				return err
+			}
+			sent[string(k)] = offsets
+			return nil
+		})
+	})
+	return sent, err
+}
+
+// PutSentReminders replaces the contents of the guild's reminders bucket
+func (bs *boltStore) PutSentReminders(guildID string, sent map[string][]string) error {
+	values := make(map[string]interface{})
+	for id, offsets := range sent {
+		values[id] = offsets
+	}
+	return bs.replaceBucket(guildID, remindersBucket, values)
+}
+
+// PutHistory writes a single key to the guild's history bucket
+func (bs *boltStore) PutHistory(guildID, key string, value []byte) error {
+	return bs.db.Update(func(tx *bolt.Tx) error {
+		b, err := guildBucket(tx, guildID, historyBucket)
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte(key), value)
+	})
+}
+
+// replaceBucket swaps the contents of one of a guild's nested buckets for
+// values, JSON encoded
+func (bs *boltStore) replaceBucket(guildID, name string, values map[string]interface{}) error {
+	encoded := make(map[string][]byte)
+	for k, value := range values {
+		v, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		encoded[k] = v
+	}
+	return bs.db.Update(func(tx *bolt.Tx) error {
+		if _, err := guildBucket(tx, guildID, name); err != nil {
+			return err
+		}
+		guild := tx.Bucket([]byte(guildsBucket)).Bucket([]byte(guildID))
+		if err := guild.DeleteBucket([]byte(name)); err != nil {
+			return err
+		}
+		b, err := guild.CreateBucket([]byte(name))
+		if err != nil {
+			return err
+		}
+		for k, v := range encoded {
+			if err := b.Put([]byte(k), v); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
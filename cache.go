@@ -0,0 +1,106 @@
+package main
+
+import (
+	"encoding/json"
+	"github.com/boltdb/bolt"
+	"github.com/jaredkotoff/meetup-bot/meetup"
+	"log"
+	"sync"
+	"time"
+)
+
+// cacheBucket is the top-level bucket persisted Meetup responses are kept in
+const cacheBucket = "meetupcache"
+
+// boltCacheStore keeps Meetup responses in bolt so they survive restarts
+type boltCacheStore struct {
+	db *bolt.DB
+
+	mu        sync.Mutex
+	nextSweep time.Time
+}
+
+// Get returns the cached entry for key, unless it is evictable
+func (cs *boltCacheStore) Get(key string) (*meetup.CacheEntry, bool) {
+	var entry *meetup.CacheEntry
+	err := cs.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(cacheBucket))
+		if b == nil {
+			return nil
+		}
+		v := b.Get([]byte(key))
+		if v == nil {
+			return nil
+		}
+		entry = &meetup.CacheEntry{}
+		return json.Unmarshal(v, entry)
+	})
+	if err != nil {
+		log.Printf("Error reading cache: %s\n", err.Error())
+		return nil, false
+	}
+	if entry == nil || entry.Evictable(time.Now()) {
+		return nil, false
+	}
+	return entry, true
+}
+
+// Set stores entry under key. Evictable entries are deleted every
+// meetup.SweepInterval.
+func (cs *boltCacheStore) Set(key string, entry *meetup.CacheEntry) {
+	v, err := json.Marshal(entry)
+	if err == nil {
+		err = cs.db.Update(func(tx *bolt.Tx) error {
+			b, err := tx.CreateBucketIfNotExists([]byte(cacheBucket))
+			if err != nil {
+				return err
+			}
+			if err := b.Put([]byte(key), v); err != nil {
+				return err
+			}
+			if cs.sweepDue() {
+				return sweepCache(b)
+			}
+			return nil
+		})
+	}
+	if err != nil {
+		log.Printf("Error writing cache: %s\n", err.Error())
+	}
+}
+
+// sweepDue reports whether it's time to delete evictable entries, and if so
+// schedules the next sweep
+func (cs *boltCacheStore) sweepDue() bool {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	now := time.Now()
+	if now.Before(cs.nextSweep) {
+		return false
+	}
+	cs.nextSweep = now.Add(meetup.SweepInterval)
+	return true
+}
+
+// sweepCache deletes the evictable entries in the cache bucket. Entries that
+// can't be decoded are deleted too.
+func sweepCache(b *bolt.Bucket) error {
+	now := time.Now()
+	var evict [][]byte
+	err := b.ForEach(func(k, v []byte) error {
+		var entry meetup.CacheEntry
+		if json.Unmarshal(v, &entry) != nil || entry.Evictable(now) {
+			evict = append(evict, append([]byte(nil), k...))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, k := range evict {
+		if err := b.Delete(k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"github.com/boltdb/bolt"
+	"github.com/jaredkotoff/meetup-bot/meetup"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCacheRevalidation(t *testing.T) {
+	t.Parallel()
+	f := setupTest(t)
+	fm := setupMeetup(t, f)
+	f.putSetting(t, groupsKey, `["chicago-rust"]`)
+
+	// With a TTL of 0 every command revalidates, and unchanged events are
+	// answered with a 304 and served from the cache
+	f.run(testUserID, testChannelID, "!nextevent")
+	f.run(testUserID, testChannelID, "!nextevent")
+	if len(f.Sent) != 2 || !reflect.DeepEqual(f.Sent[0], f.Sent[1]) {
+		t.Errorf("sent:\n%v\nwant the same reply twice", formatSent(f.Sent))
+	}
+	if n, reused := fm.requestCount(), fm.notModifiedCount(); n != 2 || reused != 1 {
+		t.Errorf("made %v requests with %v not modified, want 2 with 1", n, reused)
+	}
+
+	// Changed events are fetched in full
+	events := fm.getEvents("chicago-rust")
+	events[0].Name = "Renamed"
+	fm.setEvents("chicago-rust", events)
+	got, err := f.bot.Meetup.Events(context.Background(), "chicago-rust", "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got[0].Name != "Renamed" {
+		t.Errorf("got %q, want the renamed event", got[0].Name)
+	}
+	if n, reused := fm.requestCount(), fm.notModifiedCount(); n != 3 || reused != 1 {
+		t.Errorf("made %v requests with %v not modified, want 3 with 1", n, reused)
+	}
+
+	// Fresh entries are used without asking Meetup at all
+	f.bot.Meetup.Cache.TTL = time.Hour
+	for i := 0; i < 2; i++ {
+		if _, err := f.bot.Meetup.Group(context.Background(), "chicago-rust"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := fm.requestCount(); n != 4 {
+		t.Errorf("made %v requests, want 4", n)
+	}
+}
+
+func TestBoltCacheStoreEviction(t *testing.T) {
+	t.Parallel()
+	cs := &boltCacheStore{db: openTestDB(t, nil).db}
+	now := time.Now()
+	cs.Set("stale", &meetup.CacheEntry{Body: []byte("1"), Expires: now.Add(-time.Hour), Evict: now.Add(time.Hour)})
+	cs.Set("evicted", &meetup.CacheEntry{Body: []byte("2"), Expires: now.Add(-time.Hour), Evict: now.Add(-time.Minute)})
+
+	// Expired entries are still returned for revalidation until they're
+	// evictable
+	if entry, ok := cs.Get("stale"); !ok || string(entry.Body) != "1" {
+		t.Errorf("Get(stale) = %v, %v", entry, ok)
+	}
+	if entry, ok := cs.Get("evicted"); ok {
+		t.Errorf("Get(evicted) = %v, want nothing", entry)
+	}
+
+	// The next sweep deletes evictable entries from the db
+	cs.nextSweep = time.Time{}
+	cs.Set("fresh", &meetup.CacheEntry{Body: []byte("3"), Expires: now.Add(time.Hour)})
+	var keys []string
+	if err := cs.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(cacheBucket)).ForEach(func(k, v []byte) error {
+			keys = append(keys, string(k))
+			return nil
+		})
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"fresh", "stale"}; !reflect.DeepEqual(keys, want) {
+		t.Errorf("cache holds %v, want %v", keys, want)
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"github.com/bwmarrin/discordgo"
+	"github.com/jaredkotoff/meetup-bot/meetup"
+	"strings"
+)
+
+// watchKey holds the JSON list of event fields changes are announced for
+const watchKey = "watch"
+
+// watchableFields are the event fields that can be watched for changes, in the
+// order they are listed in change notices
+var watchableFields = []string{"name", "time", "venue"}
+
+func init() {
+	router.Register(&Command{
+		Name:        "watch",
+		Description: "Sets which event fields are watched for changes",
+		Usage:       "[fields...|none]",
+		Args:        []Arg{{Name: "fields", Optional: true, Rest: true}},
+		Permission:  discordgo.PermissionManageServer,
+		Examples:    []string{"", "time venue", "none"},
+		Handler:     setWatch,
+	})
+}
+
+// getWatchedFields returns the fields the guild watches, defaulting to all
+func (b *Bot) getWatchedFields(guildID string) ([]string, error) {
+	raw, err := b.Store.Setting(guildID, watchKey)
+	if err != nil || len(raw) == 0 {
+		return watchableFields, err
+	}
+	var fields []string
+	err = b.getGuildJSON(guildID, watchKey, &fields)
+	return fields, err
+}
+
+// eventChanges returns a diff line pair for every watched field that differs
+// between the old and current versions of an event
+func eventChanges(old, cur meetup.Event, fields []string, ts timeSettings) []string {
+	var lines []string
+	diff := func(label, before, after string) {
+		if before != after {
+			lines = append(lines, fmt.Sprintf("- %v: %v", label, before))
+			lines = append(lines, fmt.Sprintf("+ %v: %v", label, after))
+		}
+	}
+
+	for _, field := range watchableFields {
+		if !containsString(fields, field) {
+			continue
+		}
+		switch field {
+		case "name":
+			diff("Name", old.Name, cur.Name)
+		case "time":
+			diff("Time", ts.format(old), ts.format(cur))
+		case "venue":
+			before, after := "None", "None"
+			if old.Venue.Name != "" {
+				before = strings.Replace(venueString(old.Venue), "`", "", -1)
+			}
+			if cur.Venue.Name != "" {
+				after = strings.Replace(venueString(cur.Venue), "`", "", -1)
+			}
+			diff("Venue", before, after)
+		}
+	}
+	return lines
+}
+
+// changeNotice returns the message to post when an event changes between
+// polls, or "" if nothing worth announcing changed
+func changeNotice(old, cur meetup.Event, fields []string, ts timeSettings) string {
+	if cur.Status == "cancelled" && old.Status != "cancelled" {
+		return fmt.Sprintf("Event cancelled: `%v` - %v\n%v", cur.Name, ts.format(cur), cur.Link)
+	}
+	if cur.Status != "upcoming" || old.Updated == cur.Updated {
+		return ""
+	}
+
+	lines := eventChanges(old, cur, fields, ts)
+	if len(lines) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("Event changed: `%v`\n```diff\n%v\n```\n%v",
+		cur.Name, strings.Join(lines, "\n"), cur.Link)
+}
+
+// Sets or shows which event fields are watched for changes
+func setWatch(ctx *Context) error {
+	channel, err := getChannel(ctx.Session, ctx.Message.ChannelID)
+	if err != nil {
+		return err
+	}
+
+	arg := strings.ToLower(ctx.Arg("fields"))
+	if arg == "" {
+		fields, err := ctx.Bot.getWatchedFields(channel.GuildID)
+		if err != nil {
+			return err
+		}
+		if len(fields) == 0 {
+			ctx.Reply("No fields are watched, only cancellations are announced")
+			return nil
+		}
+		ctx.Reply("Changes are announced for: " + strings.Join(fields, ", "))
+		return nil
+	}
+
+	fields := []string{}
+	if arg != "none" {
+		for _, field := range strings.Fields(arg) {
+			if !containsString(watchableFields, field) {
+				ctx.Reply(fmt.Sprintf("Unknown field %v, pick from: %v or none",
+					field, strings.Join(watchableFields, ", ")))
+				return nil
+			}
+			if !containsString(fields, field) {
+				fields = append(fields, field)
+			}
+		}
+	}
+
+	if err := ctx.Bot.putGuildJSON(channel.GuildID, watchKey, fields); err != nil {
+		return err
+	}
+	if len(fields) == 0 {
+		ctx.Reply("Only cancellations will be announced")
+		return nil
+	}
+	ctx.Reply("Changes will be announced for: " + strings.Join(fields, ", "))
+	return nil
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"github.com/jaredkotoff/meetup-bot/meetup"
+	"testing"
+	"time"
+)
+
+func TestWatch(t *testing.T) {
+	t.Parallel()
+	runCommandTests(t, []commandTest{
+		{
+			name:     "default",
+			commands: []string{"!watch"},
+			want:     []sentMessage{reply("Changes are announced for: name, time, venue")},
+		},
+		{
+			name:     "set",
+			commands: []string{"!watch TIME venue time"},
+			want:     []sentMessage{reply("Changes will be announced for: time, venue")},
+		},
+		{
+			name:     "show set",
+			commands: []string{"!watch venue", "!watch"},
+			want:     []sentMessage{reply("Changes are announced for: venue")},
+		},
+		{
+			name:     "none",
+			commands: []string{"!watch none"},
+			want:     []sentMessage{reply("Only cancellations will be announced")},
+		},
+		{
+			name:     "show none",
+			commands: []string{"!watch none", "!watch"},
+			want:     []sentMessage{reply("No fields are watched, only cancellations are announced")},
+		},
+		{
+			name:     "unknown field",
+			commands: []string{"!watch time date"},
+			want:     []sentMessage{reply("Unknown field date, pick from: name, time, venue or none")},
+		},
+		{
+			name:     "denied",
+			author:   testUserID,
+			commands: []string{"!watch none"},
+			want:     denied("watch"),
+		},
+	})
+}
+
+func TestChangeNotice(t *testing.T) {
+	t.Parallel()
+	ts := timeSettings{Location: time.UTC, Layout: defaultTimeFormat}
+	old := meetup.Event{
+		Name:    "Go Night",
+		Status:  "upcoming",
+		Time:    1559673000000,
+		Updated: 1,
+		Venue:   meetup.Venue{Name: "Braintree"},
+		Link:    "https://www.meetup.com/golang-chicago/events/1/",
+	}
+	changed := func(change func(e *meetup.Event)) meetup.Event {
+		e := old
+		e.Updated = 2
+		change(&e)
+		return e
+	}
+	renamed := changed(func(e *meetup.Event) { e.Name = "Go Night: Generics" })
+	moved := changed(func(e *meetup.Event) { e.Time += time.Hour.Nanoseconds() / 1e6 })
+	online := changed(func(e *meetup.Event) { e.Venue = meetup.Venue{} })
+	cancelled := changed(func(e *meetup.Event) { e.Status = "cancelled" })
+
+	tests := []struct {
+		name   string
+		cur    meetup.Event
+		fields []string
+		want   string
+	}{
+		{"unchanged", old, watchableFields, ""},
+		{
+			"name", renamed, watchableFields,
+			"Event changed: `Go Night: Generics`\n```diff\n- Name: Go Night\n+ Name: Go Night: Generics\n```\n" + old.Link,
+		},
+		{
+			"time", moved, watchableFields,
+			"Event changed: `Go Night`\n```diff\n- Time: Tue Jun 4 6:30 PM UTC\n+ Time: Tue Jun 4 7:30 PM UTC\n```\n" + old.Link,
+		},
+		{
+			"venue", online, watchableFields,
+			"Event changed: `Go Night`\n```diff\n- Venue: Braintree\n+ Venue: None\n```\n" + old.Link,
+		},
+		{"unwatched field", moved, []string{"name", "venue"}, ""},
+		{"nothing watched", renamed, nil, ""},
+		// Without a new update time nothing is compared
+		{"not updated", func() meetup.Event { e := renamed; e.Updated = old.Updated; return e }(), watchableFields, ""},
+		{"cancelled", cancelled, nil, "Event cancelled: `Go Night` - Tue Jun 4 6:30 PM UTC\n" + old.Link},
+	}
+	for _, test := range tests {
+		if got := changeNotice(old, test.cur, test.fields, ts); got != test.want {
+			t.Errorf("%v: changeNotice() = %q, want %q", test.name, got, test.want)
+		}
+	}
+
+	// A cancellation is only announced once
+	if got := changeNotice(cancelled, cancelled, watchableFields, ts); got != "" {
+		t.Errorf("changeNotice() of an already cancelled event = %q", got)
+	}
+}
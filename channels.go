@@ -0,0 +1,169 @@
+package main
+
+import (
+	"fmt"
+	"github.com/bwmarrin/discordgo"
+	"log"
+	"sort"
+	"strings"
+)
+
+const (
+	// announceChannelKey holds the channel ID new events and changes are
+	// announced in
+	announceChannelKey = "announcechannel"
+	// reminderChannelKey holds the channel ID reminders are posted in,
+	// defaulting to the announcement channel
+	reminderChannelKey = "reminderchannel"
+	// logChannelKey holds the channel ID the bot reports problems and
+	// setting changes in
+	logChannelKey = "logchannel"
+)
+
+// channelKinds maps the names used with !setchannel to their bolt keys
+var channelKinds = map[string]string{
+	"announce":  announceChannelKey,
+	"reminders": reminderChannelKey,
+	"log":       logChannelKey,
+}
+
+// channelDescriptions describe what each kind of channel is used for
+var channelDescriptions = map[string]string{
+	"announce":  "New events and changes",
+	"reminders": "Reminders",
+	"log":       "Bot problems and setting changes",
+}
+
+func init() {
+	router.Register(&Command{
+		Name:        "setchannel",
+		Description: "Sets the channels automated posts go to",
+		Usage:       "[announce|reminders|log] [#channel|off]",
+		Args:        []Arg{{Name: "kind", Optional: true}, {Name: "channel", Optional: true}},
+		Permission:  discordgo.PermissionManageServer,
+		Examples:    []string{"", "announce #events", "reminders #general", "log off"},
+		Handler:     setChannel,
+	})
+}
+
+// parseChannel resolves a channel mention or ID, defaulting to fallback when
+// arg is empty
+func parseChannel(arg, fallback string) string {
+	if arg == "" {
+		return fallback
+	}
+	return strings.TrimSuffix(strings.TrimPrefix(arg, "<#"), ">")
+}
+
+// findGuildChannel checks channelID is a text channel in the guild that the
+// bot can post in. The returned problem is meant for the user and is empty
+// when the channel is usable.
+func (b *Bot) findGuildChannel(s Session, guildID, channelID string) (*discordgo.Channel, string, error) {
+	channels, err := s.GuildChannels(guildID)
+	if err != nil {
+		return nil, "", err
+	}
+	for _, channel := range channels {
+		if channel.ID != channelID {
+			continue
+		}
+		if channel.Type != "text" {
+			return nil, fmt.Sprintf("<#%v> isn't a text channel", channel.ID), nil
+		}
+		perms, err := memberPermissions(s, guildID, b.ID, channel)
+		if err != nil {
+			return nil, "", err
+		}
+		needed := discordgo.PermissionReadMessages | discordgo.PermissionSendMessages
+		if perms&needed != needed {
+			return nil, fmt.Sprintf("I don't have permission to post in <#%v>", channel.ID), nil
+		}
+		return channel, "", nil
+	}
+	return nil, "That channel isn't part of this server", nil
+}
+
+// guildChannel returns the channel configured under key, or "" if unset
+func (b *Bot) guildChannel(guildID, key string) string {
+	channelID, err := b.Store.Setting(guildID, key)
+	if err != nil {
+		log.Printf("Error getting %v for guild %v: %s\n", key, guildID, err.Error())
+	}
+	return string(channelID)
+}
+
+// guildLog reports a problem or change to the guild's log channel, if set
+func (b *Bot) guildLog(s Session, guildID, format string, args ...interface{}) {
+	channelID := b.guildChannel(guildID, logChannelKey)
+	if channelID == "" {
+		return
+	}
+	if err := sendMessage(s, channelID, fmt.Sprintf(format, args...)); err != nil {
+		log.Printf("Error writing to log channel for guild %v: %s\n", guildID, err.Error())
+	}
+}
+
+// Sets or shows the channels automated posts go to
+func setChannel(ctx *Context) error {
+	channel, err := getChannel(ctx.Session, ctx.Message.ChannelID)
+	if err != nil {
+		return err
+	}
+
+	kind := strings.ToLower(ctx.Arg("kind"))
+	arg := ctx.Arg("channel")
+	if kind == "" {
+		var kinds []string
+		for kind := range channelKinds {
+			kinds = append(kinds, kind)
+		}
+		sort.Strings(kinds)
+
+		lines := []string{"Automated posts go to:"}
+		for _, kind := range kinds {
+			target := "not set"
+			if channelID := ctx.Bot.guildChannel(channel.GuildID, channelKinds[kind]); channelID != "" {
+				target = "<#" + channelID + ">"
+			}
+			lines = append(lines, fmt.Sprintf(" * `%v` (%v): %v", kind, channelDescriptions[kind], target))
+		}
+		ctx.Reply(strings.Join(lines, "\n"))
+		return nil
+	}
+	if strings.HasPrefix(kind, "<#") {
+		// Support the original `!setchannel #channel` form
+		kind, arg = "announce", ctx.Arg("kind")
+	}
+
+	key, ok := channelKinds[kind]
+	if !ok {
+		ctx.Usage()
+		return nil
+	}
+
+	if strings.ToLower(arg) == "off" {
+		if err := ctx.Bot.Store.DeleteSetting(channel.GuildID, key); err != nil {
+			return err
+		}
+		ctx.Reply(fmt.Sprintf("The %v channel is no longer set", kind))
+		ctx.Bot.guildLog(ctx.Session, channel.GuildID, "%v unset the %v channel", ctx.Message.Author.Username, kind)
+		return nil
+	}
+
+	target, problem, err := ctx.Bot.findGuildChannel(ctx.Session, channel.GuildID, parseChannel(arg, channel.ID))
+	if err != nil {
+		return err
+	}
+	if problem != "" {
+		ctx.Reply(problem)
+		return nil
+	}
+
+	if err := ctx.Bot.Store.PutSetting(channel.GuildID, key, []byte(target.ID)); err != nil {
+		return err
+	}
+	ctx.Reply(fmt.Sprintf("%v will be posted in <#%v>", channelDescriptions[kind], target.ID))
+	ctx.Bot.guildLog(ctx.Session, channel.GuildID, "%v set the %v channel to <#%v>",
+		ctx.Message.Author.Username, kind, target.ID)
+	return nil
+}
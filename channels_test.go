@@ -0,0 +1,105 @@
+package main
+
+import (
+	"github.com/bwmarrin/discordgo"
+	"testing"
+)
+
+func TestSetChannel(t *testing.T) {
+	t.Parallel()
+	withLog := func(t *testing.T, f *fakeSession) {
+		f.putSetting(t, logChannelKey, testLogID)
+	}
+
+	runCommandTests(t, []commandTest{
+		{
+			name:     "show unset",
+			commands: []string{"!setchannel"},
+			want: []sentMessage{reply("Automated posts go to:\n" +
+				" * `announce` (New events and changes): not set\n" +
+				" * `log` (Bot problems and setting changes): not set\n" +
+				" * `reminders` (Reminders): not set")},
+		},
+		{
+			name:     "show set",
+			commands: []string{"!setchannel announce <#announcements>", "!setchannel"},
+			want: []sentMessage{reply("Automated posts go to:\n" +
+				" * `announce` (New events and changes): <#announcements>\n" +
+				" * `log` (Bot problems and setting changes): not set\n" +
+				" * `reminders` (Reminders): not set")},
+		},
+		{
+			name:     "current channel",
+			commands: []string{"!setchannel announce"},
+			want:     []sentMessage{reply("New events and changes will be posted in <#general>")},
+		},
+		{
+			name:     "mentioned channel",
+			commands: []string{"!setchannel reminders <#announcements>"},
+			want:     []sentMessage{reply("Reminders will be posted in <#announcements>")},
+		},
+		{
+			name:     "original form",
+			commands: []string{"!setchannel <#announcements>"},
+			want:     []sentMessage{reply("New events and changes will be posted in <#announcements>")},
+		},
+		{
+			name:     "logged",
+			setup:    withLog,
+			commands: []string{"!setchannel announce"},
+			want: []sentMessage{
+				reply("New events and changes will be posted in <#general>"),
+				logged("admin set the announce channel to <#general>"),
+			},
+		},
+		{
+			name:     "off",
+			setup:    withLog,
+			commands: []string{"!setchannel announce", "!setchannel announce off"},
+			want: []sentMessage{
+				reply("The announce channel is no longer set"),
+				logged("admin unset the announce channel"),
+			},
+		},
+		{
+			name:     "voice channel",
+			commands: []string{"!setchannel announce <#voice>"},
+			want:     []sentMessage{reply("<#voice> isn't a text channel")},
+		},
+		{
+			name:     "channel the bot can't post in",
+			commands: []string{"!setchannel announce <#readonly>"},
+			want:     []sentMessage{reply("I don't have permission to post in <#readonly>")},
+		},
+		{
+			name: "channel the bot's role can post in",
+			setup: func(t *testing.T, f *fakeSession) {
+				f.Members[testBotID].Roles = []string{"members"}
+				readonly, err := f.Channel("readonly")
+				if err != nil {
+					t.Fatal(err)
+				}
+				readonly.PermissionOverwrites = append(readonly.PermissionOverwrites,
+					&discordgo.PermissionOverwrite{ID: "members", Type: "role", Allow: discordgo.PermissionSendMessages})
+			},
+			commands: []string{"!setchannel announce <#readonly>"},
+			want:     []sentMessage{reply("New events and changes will be posted in <#readonly>")},
+		},
+		{
+			name:     "channel in another server",
+			commands: []string{"!setchannel announce <#elsewhere>"},
+			want:     []sentMessage{reply("That channel isn't part of this server")},
+		},
+		{
+			name:     "unknown kind",
+			commands: []string{"!setchannel everything"},
+			want:     []sentMessage{reply("Usage: `!setchannel [announce|reminders|log] [#channel|off]`")},
+		},
+		{
+			name:     "denied",
+			author:   testUserID,
+			commands: []string{"!setchannel announce"},
+			want:     denied("setchannel"),
+		},
+	})
+}
@@ -1,167 +1,219 @@
 package main
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
-	"github.com/boltdb/bolt"
 	"github.com/bwmarrin/discordgo"
-	"io/ioutil"
+	"github.com/jaredkotoff/meetup-bot/meetup"
 	"log"
-	"net/http"
+	"strconv"
 	"strings"
 )
 
+const (
+	// defaultListCount is how many events !getevents shows per page
+	defaultListCount = 5
+	// maxListCount is the most events !getevents shows per page
+	maxListCount = 10
+	// maxListEvents is how far out !getevents can page
+	maxListEvents = 100
+	// nextEventKey is where the last next event found is kept in a guild's
+	// history
+	nextEventKey = "nextevent"
+)
+
+func init() {
+	router.Register(&Command{
+		Name:        "setgroup",
+		Description: "Sets the meetup group for the server",
+		Args:        []Arg{{Name: "urlname"}},
+		Permission:  discordgo.PermissionManageServer,
+		Examples:    []string{"golang-chicago"},
+		Handler:     setGroup,
+	})
+	router.Register(&Command{
+		Name:        "getevents",
+		Aliases:     []string{"events"},
+		Description: "Lists upcoming events for the server's group",
+		Args:        []Arg{{Name: "count", Optional: true}, {Name: "page", Optional: true}},
+		Examples:    []string{"", "10", "5 2"},
+		Handler:     getEvents,
+	})
+	router.Register(&Command{
+		Name:        "nextevent",
+		Aliases:     []string{"next"},
+		Description: "Shows the next upcoming, public event",
+		Examples:    []string{""},
+		Handler:     nextEvent,
+	})
+}
+
 // This function will be called (due to AddHandler above) every time a new
 // message is created on any channel that the autenticated bot has access to.
-func messageCreate(s *discordgo.Session, m *discordgo.MessageCreate) {
+func (b *Bot) messageCreate(s *discordgo.Session, m *discordgo.MessageCreate) {
 	// Ignore all messages created by the bot itself
-	if m.Author.ID == BotID {
+	if m.Author.ID == b.ID {
 		return
 	}
+	// Commands arriving during shutdown are dropped rather than cut short
+	if !b.Work.Start() {
+		return
+	}
+	defer b.Work.Done()
 
-	// Sets the meetup group needed for future commands
-	// TODO Add permissions: only admins should be able to set the group for
-	// the server
-	if strings.HasPrefix(m.Content, "!setgroup") {
-		urlName := strings.TrimSpace(strings.TrimPrefix(m.Content, "!setgroup"))
-		url := hostname + "/" + urlName + "?key=" + config.APIKey
-		resp, err := http.Get(url)
-		if err != nil {
-			log.Printf("Error getting group: %s\n", err.Error())
-			s.ChannelMessageSend(m.ChannelID, err.Error())
-			return
-		}
-		defer resp.Body.Close()
-
-		// meetup 404s on nonexistent group names
-		if resp.StatusCode == 404 {
-			// TODO pull error message from meetup's json response
-			s.ChannelMessageSend(m.ChannelID, "Invalid group urlname")
-			return
-		}
+	router.Dispatch(b, s, m)
+}
 
-		channel, err := getChannel(s, m.ChannelID)
-		if err != nil {
-			log.Printf("Error getting channel: %s\n", err.Error())
-			return
-		}
+// Sets the meetup group needed for future commands, replacing any groups
+// already followed
+func setGroup(ctx *Context) error {
+	urlName := ctx.Arg("urlname")
+	if ok, err := validateGroup(ctx, urlName); !ok {
+		return err
+	}
 
-		db.Update(func(tx *bolt.Tx) error {
-			b := tx.Bucket([]byte(channel.GuildID))
-			err := b.Put([]byte("urlname"), []byte(urlName))
-			return err
-		})
-		s.ChannelMessageSend(m.ChannelID, fmt.Sprintf("Group url now set to: %v\n", urlName))
-		getNext(channel)
-		printGuild(channel.GuildID)
-	}
-
-	// Gets a list of events for the currently set group
-	// TODO: finish outputt message to server
-	if strings.HasPrefix(m.Content, "!getevents") {
-		channel, err := getChannel(s, m.ChannelID)
-		if err != nil {
-			log.Printf("Error getting channel: %s\n", err.Error())
-			return
-		}
+	channel, err := getChannel(ctx.Session, ctx.Message.ChannelID)
+	if err != nil {
+		log.Printf("Error getting channel: %s\n", err.Error())
+		return nil
+	}
 
-		urlName, err := getURLName(channel.GuildID)
-		if err != nil {
-			log.Printf("Error getting urlname from GuildID: %s\n", err.Error())
-			return
-		}
+	if err := ctx.Bot.putGroups(channel.GuildID, []string{urlName}); err != nil {
+		return err
+	}
+	ctx.Reply(fmt.Sprintf("Group url now set to: %v\n", urlName))
+	ctx.Bot.guildLog(ctx.Session, channel.GuildID, "%v set the group to `%v`", ctx.Message.Author.Username, urlName)
+	ctx.Bot.getNext(ctx, channel.GuildID, []string{urlName})
+	ctx.Bot.printGuild(channel.GuildID)
+	return nil
+}
 
-		if urlName == "" {
-			s.ChannelMessageSend(m.ChannelID, "Run !setgroup first")
-			return
-		}
-		url := hostname + urlName + "/events?key=" + config.APIKey + "&page=25"
-		r, err := http.Get(url)
-		if err != nil {
-			log.Printf("Error getting events: %s\n", err.Error())
-			s.ChannelMessageSend(m.ChannelID, err.Error())
-			return
+// Lists a page of upcoming events for the server's groups
+func getEvents(ctx *Context) error {
+	count, page := defaultListCount, 1
+	var err error
+	if arg := ctx.Arg("count"); arg != "" {
+		count, err = strconv.Atoi(arg)
+		if err != nil || count < 1 || count > maxListCount {
+			ctx.Reply(fmt.Sprintf("Count must be a number from 1 to %v", maxListCount))
+			return nil
 		}
-		defer r.Body.Close()
-		contents, _ := ioutil.ReadAll(r.Body)
-		fmt.Print(string(contents))
-		s.ChannelMessageSend(m.ChannelID, string(contents))
-	}
-
-	// Returns the next upcoming, public event
-	if strings.HasPrefix(m.Content, "!nextevent") {
-		channel, err := getChannel(s, m.ChannelID)
-		if err != nil {
-			log.Printf("Error getting channel: %s\n", err.Error())
-			return
+	}
+	if arg := ctx.Arg("page"); arg != "" {
+		page, err = strconv.Atoi(arg)
+		if err != nil || page < 1 || count*page > maxListEvents {
+			ctx.Reply(fmt.Sprintf("Page must be a number from 1 to %v", maxListEvents/count))
+			return nil
 		}
+	}
 
-		urlName, err := getURLName(channel.GuildID)
-		if err != nil {
-			log.Printf("Error getting urlname: %s\n", err.Error())
-			return
-		}
+	channel, err := getChannel(ctx.Session, ctx.Message.ChannelID)
+	if err != nil {
+		log.Printf("Error getting channel: %s\n", err.Error())
+		return nil
+	}
 
-		if urlName == "" {
-			s.ChannelMessageSend(m.ChannelID, "Run !setgroup first")
-			return
-		}
-		events := getNext(channel)
-		msg := "No future, public events found"
-
-		// Check if theres any events
-		if len(events) > 0 {
-			event := events[0]
-			// Only consider public and upcoming events
-			if (event.Visibility == "public") && (event.Status == "upcoming") {
-				venueStr := ""
-				// Check if a venue exists
-				if event.Venue.Name != "" {
-					venue := event.Venue
-					// Just print the name if there's no address
-					// TODO Print address even if there's no name
-					if venue.Address1 == "" {
-						venueStr = fmt.Sprintf("\nAt: `%v`", venue.Name)
-					} else {
-						// Print full location details
-						// TODO test for missing location information
-						venueStr = fmt.Sprintf("\nAt: `%v` - %v %v, %v %v",
-							venue.Name, venue.Address1, venue.City, venue.State, venue.Zip)
-					}
-				}
-				time, _ := msToTime(event.Time)
-				// description := truncate(event.Description)
-				msg = fmt.Sprintf("Next event: `%v` - %v%v\n%v",
-					event.Name, time, venueStr, event.Link)
-			}
-		}
+	groups, err := ctx.Bot.getGroups(channel.GuildID)
+	if err != nil {
+		log.Printf("Error getting groups from GuildID: %s\n", err.Error())
+		return nil
+	}
 
-		s.ChannelMessageSend(m.ChannelID, msg)
+	if len(groups) == 0 {
+		ctx.Reply(fmt.Sprintf("Run %vsetgroup first", ctx.Prefix))
+		return nil
 	}
+
+	// Fetch one extra event to know whether there is another page
+	events, err := ctx.Bot.guildEvents(ctx, groups, count*page+1)
+	if err != nil {
+		return err
+	}
+
+	start := (page - 1) * count
+	if start >= len(events) {
+		ctx.Reply("No more upcoming events")
+		return nil
+	}
+	end := start + count
+	if end > len(events) {
+		end = len(events)
+	}
+
+	blocks := []string{fmt.Sprintf("Upcoming events for `%v` (%v-%v):",
+		strings.Join(groups, "`, `"), start+1, end)}
+	ts := ctx.Bot.getTimeSettings(channel.GuildID)
+	for i, event := range events[start:end] {
+		data := eventData(event, ts)
+		data.Index = start + i + 1
+		data.ShowGroup = len(groups) > 1
+		blocks = append(blocks, ctx.Bot.renderEvent(channel.GuildID, "listing", data))
+	}
+	if end < len(events) && end+count <= maxListEvents {
+		blocks = append(blocks, fmt.Sprintf("More: `%vgetevents %v %v`", ctx.Prefix, count, page+1))
+	}
+	ctx.Reply(strings.Join(blocks, "\n\n"))
+	return nil
 }
 
-func getNext(channel *discordgo.Channel) []Event {
-	var events []Event
+// Returns the next upcoming, public event across the server's groups
+func nextEvent(ctx *Context) error {
+	channel, err := getChannel(ctx.Session, ctx.Message.ChannelID)
+	if err != nil {
+		log.Printf("Error getting channel: %s\n", err.Error())
+		return nil
+	}
 
-	urlName, err := getURLName(channel.GuildID)
+	groups, err := ctx.Bot.getGroups(channel.GuildID)
 	if err != nil {
-		log.Printf("Error getting urlName: %s\n", err.Error())
+		log.Printf("Error getting groups: %s\n", err.Error())
+		return nil
+	}
+
+	if len(groups) == 0 {
+		ctx.Reply(fmt.Sprintf("Run %vsetgroup first", ctx.Prefix))
+		return nil
+	}
+	events := ctx.Bot.getNext(ctx, channel.GuildID, groups)
+	msg := "No future, public events found"
+
+	// Check if theres any events
+	if len(events) > 0 {
+		data := eventData(events[0], ctx.Bot.getTimeSettings(channel.GuildID))
+		data.ShowGroup = len(groups) > 1
+		msg = ctx.Bot.renderEvent(channel.GuildID, "next", data)
 	}
 
-	url := hostname + urlName + "/events?key=" + config.APIKey + "&page=1"
-	err = getJSON(url, &events)
+	ctx.Reply(msg)
+	return nil
+}
+
+// getNext gets the upcoming, public events of the groups soonest first and
+// records the next one
+func (b *Bot) getNext(ctx context.Context, guildID string, groups []string) []meetup.Event {
+	events, err := b.guildEvents(ctx, groups, pollPageSize)
 	if err != nil {
-		log.Printf("Error getJSON: %s\n", err.Error())
+		log.Printf("Error getting events: %s\n", err.Error())
 	}
 
 	if len(events) > 0 {
-		event, _ := json.Marshal(events[0])
-		db.Update(func(tx *bolt.Tx) error {
-			b := tx.Bucket([]byte(channel.GuildID))
-			err := b.Put([]byte("nextevent"), event)
-			return err
-		})
+		if err := b.putHistoryJSON(guildID, nextEventKey, events[0]); err != nil {
+			log.Printf("Error saving next event: %s\n", err.Error())
+		}
 	}
 
 	return events
 }
+
+// venueString formats a venue's name and address on one line
+func venueString(venue meetup.Venue) string {
+	// Just print the name if there's no address
+	// TODO Print address even if there's no name
+	if venue.Address1 == "" {
+		return fmt.Sprintf("`%v`", venue.Name)
+	}
+	// Print full location details
+	// TODO test for missing location information
+	return fmt.Sprintf("`%v` - %v %v, %v %v",
+		venue.Name, venue.Address1, venue.City, venue.State, venue.Zip)
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestSetGroup(t *testing.T) {
+	t.Parallel()
+	runCommandTests(t, []commandTest{
+		{
+			name:     "missing urlname",
+			commands: []string{"!setgroup"},
+			want:     []sentMessage{reply("Usage: `!setgroup <urlname>`")},
+		},
+		{
+			name:     "too many arguments",
+			commands: []string{"!setgroup golang chicago"},
+			want:     []sentMessage{reply("Usage: `!setgroup <urlname>`")},
+		},
+		{
+			name:     "denied",
+			author:   testUserID,
+			commands: []string{"!setgroup golang-chicago"},
+			want:     denied("setgroup"),
+		},
+	})
+}
+
+func TestGetEvents(t *testing.T) {
+	t.Parallel()
+	runCommandTests(t, []commandTest{
+		{
+			name:     "no groups",
+			author:   testUserID,
+			commands: []string{"!getevents"},
+			want:     []sentMessage{reply("Run !setgroup first")},
+		},
+		{
+			name:     "alias",
+			author:   testUserID,
+			commands: []string{"!events"},
+			want:     []sentMessage{reply("Run !setgroup first")},
+		},
+		{
+			name:     "count too small",
+			author:   testUserID,
+			commands: []string{"!getevents 0"},
+			want:     []sentMessage{reply("Count must be a number from 1 to 10")},
+		},
+		{
+			name:     "count not a number",
+			author:   testUserID,
+			commands: []string{"!getevents five"},
+			want:     []sentMessage{reply("Count must be a number from 1 to 10")},
+		},
+		{
+			name:     "page too far",
+			author:   testUserID,
+			commands: []string{"!getevents 5 21"},
+			want:     []sentMessage{reply("Page must be a number from 1 to 20")},
+		},
+	})
+}
+
+func TestNextEvent(t *testing.T) {
+	t.Parallel()
+	runCommandTests(t, []commandTest{
+		{
+			name:     "no groups",
+			author:   testUserID,
+			commands: []string{"!nextevent"},
+			want:     []sentMessage{reply("Run !setgroup first")},
+		},
+		{
+			name:     "alias",
+			author:   testUserID,
+			commands: []string{"!next"},
+			want:     []sentMessage{reply("Run !setgroup first")},
+		},
+		{
+			name:     "unknown command",
+			author:   testUserID,
+			commands: []string{"!nextevents"},
+			want:     nil,
+		},
+	})
+}
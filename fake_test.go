@@ -0,0 +1,257 @@
+package main
+
+import (
+	"fmt"
+	"github.com/bwmarrin/discordgo"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const (
+	testGuildID   = "guild"
+	testChannelID = "general"
+	testLogID     = "logs"
+	testBotID     = "bot"
+	// testAdminID has Manage Server
+	testAdminID = "admin"
+	// testUserID has no special permissions
+	testUserID = "user"
+	// testOrganizerID has no special permissions but holds the Organizers role
+	testOrganizerID = "organizer"
+)
+
+// basicPerms lets a user read and post in a channel
+const basicPerms = discordgo.PermissionReadMessages | discordgo.PermissionSendMessages
+
+// sentMessage is a message the bot sent through a fakeSession
+type sentMessage struct {
+	ChannelID string
+	Content   string
+}
+
+// reply is a message sent to the channel test commands are run in
+func reply(content string) sentMessage {
+	return sentMessage{testChannelID, content}
+}
+
+// logged is a message sent to the test guild's log channel
+func logged(content string) sentMessage {
+	return sentMessage{testLogID, content}
+}
+
+// fakeSession is a Session for a single guild that records every message
+// sent instead of talking to Discord
+type fakeSession struct {
+	// bot handles the commands run in the guild
+	bot *Bot
+
+	Channels []*discordgo.Channel
+	Roles    []*discordgo.Role
+	Members  map[string]*discordgo.Member
+	// Perms holds each command author's permissions in every channel. The
+	// bot's own come from Roles and each channel's overwrites instead.
+	Perms map[string]int
+	// SendErr is returned by ChannelMessageSend when set
+	SendErr error
+	// Lookups counts calls to each method that asks Discord about the guild
+	Lookups map[string]int
+	// Sent records every message sent, in order
+	Sent []sentMessage
+}
+
+// newFakeSession returns a session for a guild with a few channels, roles and
+// members to run commands against
+func newFakeSession() *fakeSession {
+	text := func(id string) *discordgo.Channel {
+		return &discordgo.Channel{ID: id, GuildID: testGuildID, Name: id, Type: "text"}
+	}
+	voice := text("voice")
+	voice.Type = "voice"
+	// Nobody but admins may post in readonly
+	readonly := text("readonly")
+	readonly.PermissionOverwrites = []*discordgo.PermissionOverwrite{
+		{ID: testGuildID, Type: "role", Deny: discordgo.PermissionSendMessages},
+	}
+	member := func(id string, roles ...string) *discordgo.Member {
+		return &discordgo.Member{
+			GuildID: testGuildID,
+			User:    &discordgo.User{ID: id, Username: id},
+			Roles:   roles,
+		}
+	}
+
+	return &fakeSession{
+		Channels: []*discordgo.Channel{
+			text(testChannelID), text("announcements"), text(testLogID), readonly, voice,
+		},
+		// The bot's permissions come only from @everyone
+		Roles: []*discordgo.Role{
+			{ID: testGuildID, Name: "@everyone", Permissions: basicPerms},
+			{ID: "organizers", Name: "Organizers"},
+			{ID: "members", Name: "Members"},
+		},
+		Members: map[string]*discordgo.Member{
+			testBotID:       member(testBotID),
+			testAdminID:     member(testAdminID),
+			testUserID:      member(testUserID, "members"),
+			testOrganizerID: member(testOrganizerID, "members", "organizers"),
+		},
+		Perms: map[string]int{
+			testAdminID:     basicPerms | discordgo.PermissionManageServer,
+			testUserID:      basicPerms,
+			testOrganizerID: basicPerms,
+		},
+		Lookups: make(map[string]int),
+	}
+}
+
+// Channel returns one of the guild's channels, or a private channel for "dm"
+func (f *fakeSession) Channel(channelID string) (*discordgo.Channel, error) {
+	f.Lookups["Channel"]++
+	if channelID == "dm" {
+		return &discordgo.Channel{ID: channelID, Type: "text", IsPrivate: true}, nil
+	}
+	for _, channel := range f.Channels {
+		if channel.ID == channelID {
+			return channel, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown channel %v", channelID)
+}
+
+// ChannelMessageSend records the message
+func (f *fakeSession) ChannelMessageSend(channelID string, content string) (*discordgo.Message, error) {
+	if f.SendErr != nil {
+		return nil, f.SendErr
+	}
+	f.Sent = append(f.Sent, sentMessage{channelID, content})
+	return &discordgo.Message{ChannelID: channelID, Content: content}, nil
+}
+
+// GuildChannels returns the guild's channels
+func (f *fakeSession) GuildChannels(guildID string) ([]*discordgo.Channel, error) {
+	if guildID != testGuildID {
+		return nil, fmt.Errorf("unknown guild %v", guildID)
+	}
+	return f.Channels, nil
+}
+
+// GuildMember returns one of the guild's members
+func (f *fakeSession) GuildMember(guildID, userID string) (*discordgo.Member, error) {
+	f.Lookups["GuildMember"]++
+	member, ok := f.Members[userID]
+	if !ok || guildID != testGuildID {
+		return nil, fmt.Errorf("unknown member %v", userID)
+	}
+	return member, nil
+}
+
+// GuildRoles returns the guild's roles
+func (f *fakeSession) GuildRoles(guildID string) ([]*discordgo.Role, error) {
+	if guildID != testGuildID {
+		return nil, fmt.Errorf("unknown guild %v", guildID)
+	}
+	return f.Roles, nil
+}
+
+// UserChannelPermissions returns a user's permissions in a channel
+func (f *fakeSession) UserChannelPermissions(userID, channelID string) (int, error) {
+	f.Lookups["UserChannelPermissions"]++
+	return f.Perms[userID], nil
+}
+
+// run dispatches content as a message from authorID in channelID
+func (f *fakeSession) run(authorID, channelID, content string) {
+	router.Dispatch(f.bot, f, &discordgo.MessageCreate{Message: &discordgo.Message{
+		ID:        "message",
+		ChannelID: channelID,
+		Content:   content,
+		Author:    &discordgo.User{ID: authorID, Username: authorID},
+	}})
+}
+
+// setupTest creates a bot with a fresh in-memory store with the test guild in
+// it and returns a fake session for that guild
+func setupTest(t *testing.T) *fakeSession {
+	cfg := &Config{Token: "test-token", PollInterval: "10m", CacheTTL: "5m", Prefix: "!"}
+	f := newFakeSession()
+	f.bot = newBot(cfg, newMemoryStore())
+	f.bot.ID = testBotID
+	if _, err := f.bot.Store.EnsureGuild(testGuildID); err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+// commandTest runs a series of commands and checks what the last one sent
+type commandTest struct {
+	name string
+	// setup prepares the guild before any commands are run
+	setup func(t *testing.T, f *fakeSession)
+	// author sends the commands, defaulting to testAdminID
+	author string
+	// channel the commands are sent in, defaulting to testChannelID
+	channel string
+	// commands are run in order. Only the messages sent by the last are
+	// checked.
+	commands []string
+	want     []sentMessage
+}
+
+// runCommandTests runs each test in parallel against a fresh guild
+func runCommandTests(t *testing.T, tests []commandTest) {
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			f := setupTest(t)
+			if test.setup != nil {
+				test.setup(t, f)
+			}
+			author, channel := test.author, test.channel
+			if author == "" {
+				author = testAdminID
+			}
+			if channel == "" {
+				channel = testChannelID
+			}
+
+			for i, command := range test.commands {
+				if i == len(test.commands)-1 {
+					f.Sent = nil
+				}
+				f.run(author, channel, command)
+			}
+			if !reflect.DeepEqual(f.Sent, test.want) {
+				t.Errorf("%v sent:\n%v\nwant:\n%v", test.commands[len(test.commands)-1],
+					formatSent(f.Sent), formatSent(test.want))
+			}
+		})
+	}
+}
+
+// formatSent lays out messages one per line for failure output
+func formatSent(sent []sentMessage) string {
+	var lines []string
+	for _, msg := range sent {
+		lines = append(lines, fmt.Sprintf("  #%v: %q", msg.ChannelID, msg.Content))
+	}
+	if len(lines) == 0 {
+		return "  (nothing)"
+	}
+	return strings.Join(lines, "\n")
+}
+
+// putSetting stores a setting for the test guild, failing the test on error
+func (f *fakeSession) putSetting(t *testing.T, key, value string) {
+	if err := f.bot.Store.PutSetting(testGuildID, key, []byte(value)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// denied is the reply to a user without permission to run command
+func denied(command string) []sentMessage {
+	return []sentMessage{reply(fmt.Sprintf(
+		"You need the Manage Server permission or an allowed role to run `!%v`", command))}
+}
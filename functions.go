@@ -1,70 +1,149 @@
 package main
 
 import (
-	"encoding/json"
-	"fmt"
-	"github.com/boltdb/bolt"
+	"context"
 	"github.com/bwmarrin/discordgo"
-	"net/http"
+	"github.com/jaredkotoff/meetup-bot/meetup"
+	"strings"
 	"time"
+	"unicode"
+	"unicode/utf8"
 )
 
+// discordMessageLimit is the most characters Discord accepts in one message
+const discordMessageLimit = 2000
+
 func check(e error) {
 	if e != nil {
 		panic(e)
 	}
 }
 
-func getChannel(s *discordgo.Session, channelID string) (*discordgo.Channel, error) {
+// getChannel looks a channel up in the session's state when there is one, so
+// only channels it hasn't seen cost a request to Discord
+func getChannel(s Session, channelID string) (*discordgo.Channel, error) {
+	if ds, ok := s.(*discordgo.Session); ok && ds.StateEnabled {
+		if channel, err := ds.State.Channel(channelID); err == nil {
+			return channel, nil
+		}
+	}
 	return s.Channel(channelID)
 }
 
-func getURLName(guildID string) (string, error) {
-	var v []byte
-	var err error
-	err = db.View(func(tx *bolt.Tx) error {
-		b := tx.Bucket([]byte(guildID))
-		v = b.Get([]byte("urlname"))
-		return nil
-	})
-	return string(v), err
+// containsString reports whether list contains str
+func containsString(list []string, str string) bool {
+	for _, item := range list {
+		if item == str {
+			return true
+		}
+	}
+	return false
 }
 
-func printGuild(guildID string) {
-	db.View(func(tx *bolt.Tx) error {
-		// Assume bucket exists and has keys
-		b := tx.Bucket([]byte(guildID))
+// removeString returns list without any occurrences of str
+func removeString(list []string, str string) []string {
+	result := list[:0]
+	for _, item := range list {
+		if item != str {
+			result = append(result, item)
+		}
+	}
+	return result
+}
 
-		c := b.Cursor()
+// groupEvents gets the window of upcoming and cancelled events tracked for a
+// group. Every caller uses the same query so they share cached responses.
+func (b *Bot) groupEvents(ctx context.Context, urlName string) ([]meetup.Event, error) {
+	return b.Meetup.Events(ctx, urlName, "upcoming,cancelled", pollPageSize)
+}
 
-		for k, v := c.First(); k != nil; k, v = c.Next() {
-			fmt.Printf("key=%s, value=%s\n", k, v)
+// upcomingEvents filters events down to the upcoming, public ones
+func upcomingEvents(events []meetup.Event) []meetup.Event {
+	var upcoming []meetup.Event
+	for _, event := range events {
+		if event.Status == "upcoming" && event.Visibility == "public" {
+			upcoming = append(upcoming, event)
 		}
-
-		return nil
-	})
+	}
+	return upcoming
 }
 
-// Helper function to unmarshle json data into structs
-func getJSON(url string, target interface{}) error {
-	r, err := http.Get(url)
-	if err != nil {
-		return err
+// splitMessage breaks msg into chunks no longer than limit, preferring to cut
+// between paragraphs, then lines, then words. Chunks never split a UTF-8
+// sequence.
+func splitMessage(msg string, limit int) []string {
+	var chunks []string
+	for len(msg) > limit {
+		cut := -1
+		for _, sep := range []string{"\n\n", "\n", " "} {
+			if i := strings.LastIndex(msg[:limit], sep); i > 0 {
+				cut = i
+				break
+			}
+		}
+		next := cut
+		if cut < 0 {
+			// No separator, so cut at the last rune boundary that fits
+			cut = limit
+			for cut > 0 && !utf8.RuneStart(msg[cut]) {
+				cut--
+			}
+			next = cut
+		} else {
+			next = cut + 1
+		}
+		// Cutting at leading spaces leaves nothing to send
+		if chunk := strings.TrimRight(msg[:cut], "\n "); chunk != "" {
+			chunks = append(chunks, chunk)
+		}
+		msg = strings.TrimLeft(msg[next:], "\n")
+	}
+	if msg != "" {
+		chunks = append(chunks, msg)
 	}
-	defer r.Body.Close()
+	return chunks
+}
 
-	return json.NewDecoder(r.Body).Decode(target)
+// sendMessage sends msg to a channel, split across as many messages as
+// Discord's length limit requires
+func sendMessage(s Session, channelID, msg string) error {
+	for _, chunk := range splitMessage(msg, discordMessageLimit) {
+		if _, err := s.ChannelMessageSend(channelID, chunk); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
-// Helper function to convert ms since epoch to ANSIC time format
-func msToTime(ms int64) (string, error) {
-	return time.Unix(0, ms*int64(time.Millisecond)).Format(time.ANSIC), nil
+// Helper function to convert ms since epoch to a time
+func msToTime(ms int64) time.Time {
+	return time.Unix(0, ms*int64(time.Millisecond))
 }
 
-// Helper function to truncates a string and adds ellipsis
+// truncateLength is how many characters truncate keeps, including the ellipsis
+const truncateLength = 150
+
+// Helper function to truncate a string and add an ellipsis
 func truncate(str string) string {
-	if len(str) > 150 {
-		return string(str[:47] + "...")
+	return truncateWords(str, truncateLength)
+}
+
+// truncateWords shortens str to at most max characters, cutting at the last
+// word boundary that keeps most of the text and adding an ellipsis
+func truncateWords(str string, max int) string {
+	runes := []rune(str)
+	if len(runes) <= max {
+		return str
+	}
+	cut := max - len("...")
+	if cut < 0 {
+		cut = 0
+	}
+	for i := cut; i > cut/2; i-- {
+		if unicode.IsSpace(runes[i]) {
+			cut = i
+			break
+		}
 	}
-	return str
+	return strings.TrimRightFunc(string(runes[:cut]), unicode.IsSpace) + "..."
 }
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestTruncateWords(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		str  string
+		max  int
+		want string
+	}{
+		{"short", 10, "short"},
+		{"exactly ten", 11, "exactly ten"},
+		{"cut at a word boundary", 15, "cut at a..."},
+		{"cut at a word boundary", 12, "cut at a..."},
+		{"averyveryverylongword", 10, "averyve..."},
+		{"one averyveryverylongword", 12, "one avery..."},
+		{"héllo wörld ünïcode", 14, "héllo wörld..."},
+		{"日本語のテキストです", 6, "日本語..."},
+	}
+
+	for _, test := range tests {
+		got := truncateWords(test.str, test.max)
+		if got != test.want {
+			t.Errorf("truncateWords(%q, %v) = %q, want %q", test.str, test.max, got, test.want)
+		}
+		if !utf8.ValidString(got) || utf8.RuneCountInString(got) > test.max {
+			t.Errorf("truncateWords(%q, %v) = %q, which is invalid or too long", test.str, test.max, got)
+		}
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	t.Parallel()
+	long := strings.Repeat("Gophers ", 30)
+	got := truncate(long)
+	if utf8.RuneCountInString(got) > truncateLength || !strings.HasSuffix(got, "Gophers...") {
+		t.Errorf("truncate(%q) = %q", long, got)
+	}
+	if got := truncate("Short enough"); got != "Short enough" {
+		t.Errorf("truncate(%q) = %q", "Short enough", got)
+	}
+}
+
+func TestSplitMessage(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		msg  string
+		want []string
+	}{
+		{"short", []string{"short"}},
+		{"one\n\ntwo six", []string{"one", "two six"}},
+		{"one two\nthree", []string{"one two", "three"}},
+		{"one two three", []string{"one two", "three"}},
+		{"onetwothree", []string{"onetwoth", "ree"}},
+		{"日本語のテキスト", []string{"日本", "語の", "テキ", "スト"}},
+		// Cutting in leading padding doesn't send an empty message
+		{"          1", []string{"  1"}},
+	}
+	for _, test := range tests {
+		if got := splitMessage(test.msg, 8); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("splitMessage(%q, 8) = %q, want %q", test.msg, got, test.want)
+		}
+	}
+}
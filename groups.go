@@ -0,0 +1,277 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/bwmarrin/discordgo"
+	"github.com/jaredkotoff/meetup-bot/meetup"
+	"log"
+	"sort"
+	"strings"
+)
+
+const (
+	// groupsKey holds the JSON list of urlnames a guild follows
+	groupsKey = "groups"
+	// groupChannelsKey holds a JSON map of urlname to the channel that group's
+	// events are announced in, overriding the announcement channel
+	groupChannelsKey = "groupchannels"
+	// seededGroupsKey holds the JSON list of groups whose existing events have
+	// been recorded, so only events posted afterwards are announced
+	seededGroupsKey = "seeded"
+)
+
+func init() {
+	router.Register(&Command{
+		Name:        "addgroup",
+		Description: "Follows another meetup group, optionally announcing it in its own channel",
+		Args:        []Arg{{Name: "urlname"}, {Name: "#channel", Optional: true}},
+		Permission:  discordgo.PermissionManageServer,
+		Examples:    []string{"chicago-rust", "chicago-rust #rust"},
+		Handler:     addGroup,
+	})
+	router.Register(&Command{
+		Name:        "removegroup",
+		Description: "Stops following a meetup group",
+		Args:        []Arg{{Name: "urlname"}},
+		Permission:  discordgo.PermissionManageServer,
+		Examples:    []string{"chicago-rust"},
+		Handler:     removeGroup,
+	})
+	router.Register(&Command{
+		Name:        "groups",
+		Description: "Lists the meetup groups the server follows",
+		Examples:    []string{""},
+		Handler:     listGroups,
+	})
+}
+
+// getGroups returns the urlnames a guild follows
+func (b *Bot) getGroups(guildID string) ([]string, error) {
+	var groups []string
+	err := b.getGuildJSON(guildID, groupsKey, &groups)
+	return groups, err
+}
+
+// putGroups stores the urlnames a guild follows, dropping tracking state and
+// channel routes for any group no longer in the list
+func (b *Bot) putGroups(guildID string, groups []string) error {
+	if err := b.putGuildJSON(guildID, groupsKey, groups); err != nil {
+		return err
+	}
+
+	routes := make(map[string]string)
+	if err := b.getGuildJSON(guildID, groupChannelsKey, &routes); err != nil {
+		return err
+	}
+	for group := range routes {
+		if !containsString(groups, group) {
+			delete(routes, group)
+		}
+	}
+	if err := b.putGuildJSON(guildID, groupChannelsKey, routes); err != nil {
+		return err
+	}
+
+	var seeded []string
+	if err := b.getGuildJSON(guildID, seededGroupsKey, &seeded); err != nil {
+		return err
+	}
+	var kept []string
+	for _, group := range seeded {
+		if containsString(groups, group) {
+			kept = append(kept, group)
+		}
+	}
+	return b.putGuildJSON(guildID, seededGroupsKey, kept)
+}
+
+// getGroupChannels returns the guild's map of urlname to announcement channel
+func (b *Bot) getGroupChannels(guildID string) (map[string]string, error) {
+	routes := make(map[string]string)
+	err := b.getGuildJSON(guildID, groupChannelsKey, &routes)
+	return routes, err
+}
+
+// findGroup returns the followed urlname matching name, ignoring case
+func findGroup(groups []string, name string) (string, bool) {
+	for _, group := range groups {
+		if strings.EqualFold(group, name) {
+			return group, true
+		}
+	}
+	return "", false
+}
+
+// labelEvents tags events with the followed group's urlname so they can be
+// matched back to the group, and fills in a name to label them with
+func labelEvents(events []meetup.Event, urlName string) {
+	for i := range events {
+		events[i].Group.URLName = urlName
+		if events[i].Group.Name == "" {
+			events[i].Group.Name = urlName
+		}
+	}
+}
+
+// guildEvents merges the upcoming, public events of every group, soonest
+// first. count is how many events per group are needed; windows up to
+// pollPageSize share the poller's cached responses unless cancelled and
+// private events leave them short. An error is only returned if every group
+// failed.
+func (b *Bot) guildEvents(ctx context.Context, groups []string, count int) ([]meetup.Event, error) {
+	var merged []meetup.Event
+	var lastErr error
+	for _, group := range groups {
+		var events []meetup.Event
+		var err error
+		if count <= pollPageSize {
+			events, err = b.groupEvents(ctx, group)
+			// A full window may have more upcoming events past its end. A
+			// whole page of them leaves room for private ones and is shared
+			// by every count.
+			if err == nil && len(events) == pollPageSize && len(upcomingEvents(events)) < count {
+				events, err = b.Meetup.Events(ctx, group, "upcoming", pollPageSize)
+			}
+		} else {
+			events, err = b.Meetup.Events(ctx, group, "upcoming", count)
+		}
+		if err != nil {
+			log.Printf("Error getting events for %v: %s\n", group, err.Error())
+			lastErr = err
+			continue
+		}
+		labelEvents(events, group)
+		merged = append(merged, upcomingEvents(events)...)
+	}
+	if len(merged) == 0 && lastErr != nil {
+		return nil, lastErr
+	}
+	sort.Sort(byTime(merged))
+	return merged, nil
+}
+
+// byTime sorts events soonest first
+type byTime []meetup.Event
+
+func (e byTime) Len() int           { return len(e) }
+func (e byTime) Less(i, j int) bool { return e[i].Time < e[j].Time }
+func (e byTime) Swap(i, j int)      { e[i], e[j] = e[j], e[i] }
+
+// validateGroup checks a urlname exists, replying with Meetup's message if not
+func validateGroup(ctx *Context, urlName string) (bool, error) {
+	_, err := ctx.Bot.Meetup.Group(ctx, urlName)
+	// meetup 404s on nonexistent group names
+	if meetup.IsNotFound(err) {
+		ctx.Reply("Invalid group urlname: " + err.(*meetup.Error).Message())
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Follows another meetup group
+func addGroup(ctx *Context) error {
+	urlName := ctx.Arg("urlname")
+	if ok, err := validateGroup(ctx, urlName); !ok {
+		return err
+	}
+
+	channel, err := getChannel(ctx.Session, ctx.Message.ChannelID)
+	if err != nil {
+		return err
+	}
+	groups, err := ctx.Bot.getGroups(channel.GuildID)
+	if err != nil {
+		return err
+	}
+	if existing, ok := findGroup(groups, urlName); ok {
+		urlName = existing
+	} else {
+		groups = append(groups, urlName)
+		if err := ctx.Bot.putGroups(channel.GuildID, groups); err != nil {
+			return err
+		}
+	}
+
+	if arg := ctx.Arg("#channel"); arg != "" {
+		target, problem, err := ctx.Bot.findGuildChannel(ctx.Session, channel.GuildID, parseChannel(arg, ""))
+		if err != nil {
+			return err
+		}
+		if problem != "" {
+			ctx.Reply(problem)
+			return nil
+		}
+		routes, err := ctx.Bot.getGroupChannels(channel.GuildID)
+		if err != nil {
+			return err
+		}
+		routes[urlName] = target.ID
+		if err := ctx.Bot.putGuildJSON(channel.GuildID, groupChannelsKey, routes); err != nil {
+			return err
+		}
+		ctx.Reply(fmt.Sprintf("Following `%v`, announced in <#%v>", urlName, target.ID))
+		ctx.Bot.guildLog(ctx.Session, channel.GuildID, "%v added the group `%v`, announced in <#%v>",
+			ctx.Message.Author.Username, urlName, target.ID)
+		return nil
+	}
+
+	ctx.Reply(fmt.Sprintf("Following `%v`", urlName))
+	ctx.Bot.guildLog(ctx.Session, channel.GuildID, "%v added the group `%v`", ctx.Message.Author.Username, urlName)
+	return nil
+}
+
+// Stops following a meetup group
+func removeGroup(ctx *Context) error {
+	channel, err := getChannel(ctx.Session, ctx.Message.ChannelID)
+	if err != nil {
+		return err
+	}
+	groups, err := ctx.Bot.getGroups(channel.GuildID)
+	if err != nil {
+		return err
+	}
+	urlName, ok := findGroup(groups, ctx.Arg("urlname"))
+	if !ok {
+		ctx.Reply(fmt.Sprintf("This server doesn't follow `%v`", ctx.Arg("urlname")))
+		return nil
+	}
+
+	if err := ctx.Bot.putGroups(channel.GuildID, removeString(groups, urlName)); err != nil {
+		return err
+	}
+	ctx.Reply(fmt.Sprintf("No longer following `%v`", urlName))
+	ctx.Bot.guildLog(ctx.Session, channel.GuildID, "%v removed the group `%v`", ctx.Message.Author.Username, urlName)
+	return nil
+}
+
+// Lists the meetup groups the server follows
+func listGroups(ctx *Context) error {
+	channel, err := getChannel(ctx.Session, ctx.Message.ChannelID)
+	if err != nil {
+		return err
+	}
+	groups, err := ctx.Bot.getGroups(channel.GuildID)
+	if err != nil {
+		return err
+	}
+	if len(groups) == 0 {
+		ctx.Reply(fmt.Sprintf("This server doesn't follow any groups yet, run %vaddgroup first", ctx.Prefix))
+		return nil
+	}
+	routes, err := ctx.Bot.getGroupChannels(channel.GuildID)
+	if err != nil {
+		return err
+	}
+
+	lines := []string{"This server follows:"}
+	for _, group := range groups {
+		line := fmt.Sprintf(" * `%v`", group)
+		if channelID, ok := routes[group]; ok {
+			line += fmt.Sprintf(" in <#%v>", channelID)
+		}
+		lines = append(lines, line)
+	}
+	ctx.Reply(strings.Join(lines, "\n"))
+	return nil
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestGroups(t *testing.T) {
+	t.Parallel()
+	runCommandTests(t, []commandTest{
+		{
+			name:     "none",
+			author:   testUserID,
+			commands: []string{"!groups"},
+			want:     []sentMessage{reply("This server doesn't follow any groups yet, run !addgroup first")},
+		},
+		{
+			name: "followed",
+			setup: func(t *testing.T, f *fakeSession) {
+				f.putSetting(t, groupsKey, `["golang-chicago","chicago-rust"]`)
+				f.putSetting(t, groupChannelsKey, `{"chicago-rust":"announcements"}`)
+			},
+			author:   testUserID,
+			commands: []string{"!groups"},
+			want: []sentMessage{reply("This server follows:\n" +
+				" * `golang-chicago`\n" +
+				" * `chicago-rust` in <#announcements>")},
+		},
+	})
+}
+
+func TestRemoveGroup(t *testing.T) {
+	t.Parallel()
+	following := func(t *testing.T, f *fakeSession) {
+		f.putSetting(t, groupsKey, `["golang-chicago","chicago-rust"]`)
+		f.putSetting(t, groupChannelsKey, `{"chicago-rust":"announcements"}`)
+		f.putSetting(t, logChannelKey, testLogID)
+	}
+
+	runCommandTests(t, []commandTest{
+		{
+			name:     "remove",
+			setup:    following,
+			commands: []string{"!removegroup Chicago-Rust"},
+			want: []sentMessage{
+				reply("No longer following `chicago-rust`"),
+				logged("admin removed the group `chicago-rust`"),
+			},
+		},
+		{
+			name:     "removed group is gone",
+			setup:    following,
+			commands: []string{"!removegroup chicago-rust", "!groups"},
+			want:     []sentMessage{reply("This server follows:\n * `golang-chicago`")},
+		},
+		{
+			name:     "not followed",
+			setup:    following,
+			commands: []string{"!removegroup golang-nyc"},
+			want:     []sentMessage{reply("This server doesn't follow `golang-nyc`")},
+		},
+		{
+			name:     "missing urlname",
+			commands: []string{"!removegroup"},
+			want:     []sentMessage{reply("Usage: `!removegroup <urlname>`")},
+		},
+		{
+			name:     "denied",
+			author:   testUserID,
+			commands: []string{"!removegroup golang-chicago"},
+			want:     denied("removegroup"),
+		},
+	})
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+	"github.com/bwmarrin/discordgo"
+	"log"
+	"strings"
+)
+
+// welcomedKey is set once a guild has been sent the welcome message
+const welcomedKey = "welcomed"
+
+// guildCreate is called when the bot joins a guild and for every guild it is
+// in when it connects
+func (b *Bot) guildCreate(s *discordgo.Session, g *discordgo.GuildCreate) {
+	b.joinGuild(s, g.Guild)
+}
+
+// guildDelete is called when the bot is removed from a guild
+func (b *Bot) guildDelete(s *discordgo.Session, g *discordgo.GuildDelete) {
+	b.leaveGuild(s, g.Guild)
+}
+
+// joinGuild makes sure a guild the bot is in has a settings bucket,
+// restoring archived settings if the bot was added back, and welcomes new
+// guilds with setup instructions
+func (b *Bot) joinGuild(s Session, g *discordgo.Guild) {
+	// Guilds in an outage are sent unavailable and have nothing to set up
+	if g.Unavailable != nil && *g.Unavailable {
+		return
+	}
+	if !b.Work.Start() {
+		return
+	}
+	defer b.Work.Done()
+
+	restored, err := b.Store.EnsureGuild(g.ID)
+	if err != nil {
+		log.Printf("Error creating bucket for guild %v: %s\n", g.ID, err.Error())
+		return
+	}
+	if restored {
+		b.Prefixes.refresh(b.Store, g.ID)
+		// Let the guild be welcomed back
+		if err := b.Store.DeleteSetting(g.ID, welcomedKey); err != nil {
+			log.Printf("Error resetting welcome for guild %v: %s\n", g.ID, err.Error())
+		}
+	}
+
+	welcomed, err := b.Store.Setting(g.ID, welcomedKey)
+	if err != nil || len(welcomed) > 0 {
+		return
+	}
+	groups, err := b.getGroups(g.ID)
+	if err != nil || (len(groups) > 0 && !restored) {
+		// Guilds set up before welcome messages existed don't need one
+		return
+	}
+
+	channelID := b.welcomeChannel(s, g)
+	if channelID == "" {
+		return
+	}
+	msg := b.welcomeMessage()
+	if restored {
+		msg = "Welcome back! Your previous settings have been restored."
+	}
+	if _, err := s.ChannelMessageSend(channelID, msg); err != nil {
+		log.Printf("Error welcoming guild %v: %s\n", g.ID, err.Error())
+		return
+	}
+	if err := b.Store.PutSetting(g.ID, welcomedKey, []byte("true")); err != nil {
+		log.Printf("Error saving welcome for guild %v: %s\n", g.ID, err.Error())
+	}
+}
+
+// leaveGuild archives the settings of a guild the bot was removed from, or
+// purges them if PurgeOnLeave is set
+func (b *Bot) leaveGuild(s Session, g *discordgo.Guild) {
+	// Guilds going into an outage are also deleted, but the bot is still in
+	// them, so keep their settings
+	if g.Unavailable != nil && *g.Unavailable {
+		return
+	}
+	if !b.Work.Start() {
+		return
+	}
+	defer b.Work.Done()
+
+	var err error
+	if b.Config.PurgeOnLeave {
+		err = b.Store.PurgeGuild(g.ID)
+	} else {
+		err = b.Store.ArchiveGuild(g.ID)
+	}
+	if err != nil {
+		log.Printf("Error removing settings for guild %v: %s\n", g.ID, err.Error())
+	}
+	b.Prefixes.refresh(b.Store, g.ID)
+}
+
+// welcomeChannel picks where to greet a guild: its default channel, whose ID
+// matches the guild's, or else the first text channel the bot can post in
+func (b *Bot) welcomeChannel(s Session, g *discordgo.Guild) string {
+	var fallback string
+	for _, channel := range g.Channels {
+		if channel.Type != "text" {
+			continue
+		}
+		perms, err := memberPermissions(s, g.ID, b.ID, channel)
+		if err != nil || perms&discordgo.PermissionSendMessages == 0 {
+			continue
+		}
+		if channel.ID == g.ID {
+			return channel.ID
+		}
+		if fallback == "" {
+			fallback = channel.ID
+		}
+	}
+	return fallback
+}
+
+// welcomeMessage explains how to set the bot up
+func (b *Bot) welcomeMessage() string {
+	p := b.Config.Prefix
+	return strings.Join([]string{
+		"Hi! I post events from meetup.com groups. Someone with the Manage Server permission can set me up:",
+		fmt.Sprintf(" * `%vsetgroup <urlname>` to follow a group, using the name from its meetup.com URL", p),
+		fmt.Sprintf(" * `%vsetchannel announce #channel` to announce new events", p),
+		fmt.Sprintf(" * `%vreminders 1d 1h` to post reminders before events", p),
+		fmt.Sprintf("Then anyone can use `%vnextevent` and `%vgetevents`.", p, p),
+	}, "\n")
+}
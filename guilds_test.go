@@ -0,0 +1,119 @@
+package main
+
+import (
+	"github.com/bwmarrin/discordgo"
+	"reflect"
+	"testing"
+)
+
+// testGuild is the test guild as Discord sends it when the bot joins
+func testGuild(f *fakeSession, unavailable bool) *discordgo.Guild {
+	return &discordgo.Guild{ID: testGuildID, Channels: f.Channels, Unavailable: &unavailable}
+}
+
+func TestJoinGuild(t *testing.T) {
+	t.Parallel()
+	f := setupTest(t)
+	f.bot.joinGuild(f, testGuild(f, false))
+	want := []sentMessage{reply(f.bot.welcomeMessage())}
+	if !reflect.DeepEqual(f.Sent, want) {
+		t.Errorf("sent:\n%v\nwant:\n%v", formatSent(f.Sent), formatSent(want))
+	}
+
+	// Reconnecting sends every guild again, which mustn't welcome it twice
+	f.Sent = nil
+	f.bot.joinGuild(f, testGuild(f, false))
+	if len(f.Sent) != 0 {
+		t.Errorf("sent on reconnecting:\n%v", formatSent(f.Sent))
+	}
+}
+
+func TestJoinGuildWelcomeChannel(t *testing.T) {
+	t.Parallel()
+	f := setupTest(t)
+	// The bot can only post through @everyone, which can't post in general
+	general, err := f.Channel(testChannelID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	general.PermissionOverwrites = []*discordgo.PermissionOverwrite{
+		{ID: testGuildID, Type: "role", Deny: discordgo.PermissionSendMessages},
+	}
+	f.bot.joinGuild(f, testGuild(f, false))
+	want := []sentMessage{{"announcements", f.bot.welcomeMessage()}}
+	if !reflect.DeepEqual(f.Sent, want) {
+		t.Errorf("sent:\n%v\nwant:\n%v", formatSent(f.Sent), formatSent(want))
+	}
+}
+
+func TestJoinGuildAlreadySetUp(t *testing.T) {
+	t.Parallel()
+	f := setupTest(t)
+	f.putSetting(t, groupsKey, `["golang-chicago"]`)
+	f.bot.joinGuild(f, testGuild(f, false))
+	if len(f.Sent) != 0 {
+		t.Errorf("welcomed a guild set up before welcomes existed:\n%v", formatSent(f.Sent))
+	}
+}
+
+func TestJoinGuildUnavailable(t *testing.T) {
+	t.Parallel()
+	f := setupTest(t)
+	if err := f.bot.Store.PurgeGuild(testGuildID); err != nil {
+		t.Fatal(err)
+	}
+	f.bot.joinGuild(f, testGuild(f, true))
+	if len(f.Sent) != 0 {
+		t.Errorf("sent to an unavailable guild:\n%v", formatSent(f.Sent))
+	}
+	if guilds, err := f.bot.Store.Guilds(); err != nil || len(guilds) != 0 {
+		t.Errorf("unavailable guild was set up: %v, %v", guilds, err)
+	}
+}
+
+func TestLeaveGuild(t *testing.T) {
+	t.Parallel()
+	f := setupTest(t)
+	f.bot.joinGuild(f, testGuild(f, false))
+	f.putSetting(t, groupsKey, `["golang-chicago"]`)
+
+	// Leaving during an outage keeps the settings in place
+	f.bot.leaveGuild(f, testGuild(f, true))
+	if groups, err := f.bot.getGroups(testGuildID); err != nil || len(groups) != 1 {
+		t.Errorf("groups after an outage = %v, %v", groups, err)
+	}
+
+	f.bot.leaveGuild(f, testGuild(f, false))
+	if guilds, err := f.bot.Store.Guilds(); err != nil || len(guilds) != 0 {
+		t.Errorf("Guilds() after leaving = %v, %v", guilds, err)
+	}
+
+	// Adding the bot back restores the settings and welcomes the guild back
+	f.Sent = nil
+	f.bot.joinGuild(f, testGuild(f, false))
+	want := []sentMessage{reply("Welcome back! Your previous settings have been restored.")}
+	if !reflect.DeepEqual(f.Sent, want) {
+		t.Errorf("sent:\n%v\nwant:\n%v", formatSent(f.Sent), formatSent(want))
+	}
+	if groups, err := f.bot.getGroups(testGuildID); err != nil || !reflect.DeepEqual(groups, []string{"golang-chicago"}) {
+		t.Errorf("restored groups = %v, %v", groups, err)
+	}
+}
+
+func TestLeaveGuildPurge(t *testing.T) {
+	t.Parallel()
+	f := setupTest(t)
+	f.bot.Config.PurgeOnLeave = true
+	f.putSetting(t, groupsKey, `["golang-chicago"]`)
+	f.bot.leaveGuild(f, testGuild(f, false))
+
+	// Nothing is left to restore, so the guild is welcomed as a new one
+	f.bot.joinGuild(f, testGuild(f, false))
+	want := []sentMessage{reply(f.bot.welcomeMessage())}
+	if !reflect.DeepEqual(f.Sent, want) {
+		t.Errorf("sent:\n%v\nwant:\n%v", formatSent(f.Sent), formatSent(want))
+	}
+	if groups, err := f.bot.getGroups(testGuildID); err != nil || len(groups) != 0 {
+		t.Errorf("groups after purging = %v, %v", groups, err)
+	}
+}
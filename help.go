@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+)
+
+func init() {
+	router.Register(&Command{
+		Name:        "help",
+		Aliases:     []string{"commands"},
+		Description: "Lists the commands you can run, or explains one of them",
+		Args:        []Arg{{Name: "command", Optional: true}},
+		Examples:    []string{"", "nextevent"},
+		Handler:     showHelp,
+	})
+}
+
+// runnable returns the commands the author of the context's message may run,
+// in registration order. If permissions can't be checked only the commands
+// anyone can run are returned.
+func runnable(ctx *Context) []*Command {
+	a, err := ctx.Bot.authorAccess(ctx.Session, ctx.Message)
+	if err != nil {
+		log.Printf("Error checking permissions: %s\n", err.Error())
+		a = &access{}
+	}
+	var commands []*Command
+	for _, cmd := range router.Commands() {
+		if a.allows(cmd) {
+			commands = append(commands, cmd)
+		}
+	}
+	return commands
+}
+
+// commandHelp describes a single command in detail
+func commandHelp(cmd *Command, prefix string) string {
+	lines := []string{fmt.Sprintf("`%v`", cmd.UsageString(prefix)), cmd.Description}
+	if len(cmd.Aliases) > 0 {
+		var aliases []string
+		for _, alias := range cmd.Aliases {
+			aliases = append(aliases, "`"+prefix+alias+"`")
+		}
+		lines = append(lines, "Also: "+strings.Join(aliases, ", "))
+	}
+	if cmd.Permission != 0 {
+		lines = append(lines, fmt.Sprintf("Needs the %v permission or an allowed role",
+			permissionString(cmd.Permission)))
+	}
+	if len(cmd.Examples) > 0 {
+		lines = append(lines, "Examples:")
+		for _, example := range cmd.Examples {
+			lines = append(lines, "`"+strings.TrimSpace(prefix+cmd.Name+" "+example)+"`")
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Lists the commands the user can run, or shows the details of one
+func showHelp(ctx *Context) error {
+	if name := ctx.Arg("command"); name != "" {
+		name = strings.TrimPrefix(name, ctx.Prefix)
+		for _, cmd := range runnable(ctx) {
+			if cmd == router.Find(name) {
+				ctx.Reply(commandHelp(cmd, ctx.Prefix))
+				return nil
+			}
+		}
+		ctx.Reply(fmt.Sprintf("There's no `%v%v` command you can run, see `%vhelp` for the ones you can",
+			ctx.Prefix, name, ctx.Prefix))
+		return nil
+	}
+
+	lines := []string{"Commands you can run:"}
+	for _, cmd := range runnable(ctx) {
+		lines = append(lines, fmt.Sprintf("`%v` : %v", cmd.UsageString(ctx.Prefix), cmd.Description))
+	}
+	lines = append(lines, fmt.Sprintf("Run `%vhelp <command>` for examples, e.g. `%vhelp nextevent`",
+		ctx.Prefix, ctx.Prefix))
+	ctx.Reply(strings.Join(lines, "\n"))
+	return nil
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestHelpLists(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name    string
+		author  string
+		channel string
+		prefix  string
+		want    []string
+		hidden  []string
+	}{
+		{
+			name:   "admin",
+			author: testAdminID,
+			want: []string{
+				"`!nextevent` : Shows the next upcoming, public event",
+				"`!setgroup <urlname>` : Sets the meetup group for the server",
+				"`!prefix [prefix|reset]` : Sets what commands start with on this server",
+			},
+		},
+		{
+			name:   "user",
+			author: testUserID,
+			want: []string{
+				"`!nextevent` : Shows the next upcoming, public event",
+				"`!getevents [count] [page]` : Lists upcoming events for the server's group",
+				"`!help [command]` : Lists the commands you can run, or explains one of them",
+			},
+			hidden: []string{"!setgroup", "!adminrole", "!prefix"},
+		},
+		{
+			name:    "private channel",
+			author:  testAdminID,
+			channel: "dm",
+			want:    []string{"`!groups` : Lists the meetup groups the server follows"},
+			hidden:  []string{"!setgroup", "!reminders"},
+		},
+		{
+			name:   "custom prefix",
+			author: testUserID,
+			prefix: "?",
+			want: []string{
+				"`?nextevent` : Shows the next upcoming, public event",
+				"Run `?help <command>` for examples, e.g. `?help nextevent`",
+			},
+			hidden: []string{"!"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			f := setupTest(t)
+			channel := test.channel
+			if channel == "" {
+				channel = testChannelID
+			}
+			prefix := "!"
+			if test.prefix != "" {
+				f.putSetting(t, prefixKey, test.prefix)
+				prefix = test.prefix
+			}
+
+			f.run(test.author, channel, prefix+"help")
+			if len(f.Sent) != 1 {
+				t.Fatalf("sent:\n%v\nwant one message", formatSent(f.Sent))
+			}
+			content := f.Sent[0].Content
+			for _, line := range test.want {
+				if !strings.Contains(content, line) {
+					t.Errorf("help is missing %q:\n%v", line, content)
+				}
+			}
+			for _, s := range test.hidden {
+				if strings.Contains(content, s) {
+					t.Errorf("help shows %q:\n%v", s, content)
+				}
+			}
+		})
+	}
+}
+
+func TestHelpCommand(t *testing.T) {
+	t.Parallel()
+	runCommandTests(t, []commandTest{
+		{
+			name:     "command",
+			author:   testUserID,
+			commands: []string{"!help getevents"},
+			want: []sentMessage{reply("`!getevents [count] [page]`\n" +
+				"Lists upcoming events for the server's group\n" +
+				"Also: `!events`\n" +
+				"Examples:\n`!getevents`\n`!getevents 10`\n`!getevents 5 2`")},
+		},
+		{
+			name:     "alias",
+			author:   testUserID,
+			commands: []string{"!help next"},
+			want: []sentMessage{reply("`!nextevent`\n" +
+				"Shows the next upcoming, public event\n" +
+				"Also: `!next`\n" +
+				"Examples:\n`!nextevent`")},
+		},
+		{
+			name:     "with prefix",
+			commands: []string{"!help !setgroup"},
+			want: []sentMessage{reply("`!setgroup <urlname>`\n" +
+				"Sets the meetup group for the server\n" +
+				"Needs the Manage Server permission or an allowed role\n" +
+				"Examples:\n`!setgroup golang-chicago`")},
+		},
+		{
+			name: "custom prefix",
+			setup: func(t *testing.T, f *fakeSession) {
+				f.putSetting(t, prefixKey, "?")
+			},
+			commands: []string{"?help removegroup"},
+			want: []sentMessage{reply("`?removegroup <urlname>`\n" +
+				"Stops following a meetup group\n" +
+				"Needs the Manage Server permission or an allowed role\n" +
+				"Examples:\n`?removegroup chicago-rust`")},
+		},
+		{
+			name:     "hidden admin command",
+			author:   testUserID,
+			commands: []string{"!help setgroup"},
+			want:     []sentMessage{reply("There's no `!setgroup` command you can run, see `!help` for the ones you can")},
+		},
+		{
+			name: "admin role",
+			setup: func(t *testing.T, f *fakeSession) {
+				f.putSetting(t, adminRolesKey, `["organizers"]`)
+			},
+			author:   testOrganizerID,
+			commands: []string{"!help watch"},
+			want: []sentMessage{reply("`!watch [fields...|none]`\n" +
+				"Sets which event fields are watched for changes\n" +
+				"Needs the Manage Server permission or an allowed role\n" +
+				"Examples:\n`!watch`\n`!watch time venue`\n`!watch none`")},
+		},
+		{
+			name:     "unknown",
+			author:   testUserID,
+			commands: []string{"!help dance"},
+			want:     []sentMessage{reply("There's no `!dance` command you can run, see `!help` for the ones you can")},
+		},
+	})
+}
+
+func TestHelpChecksPermissionsOnce(t *testing.T) {
+	t.Parallel()
+	f := setupTest(t)
+	f.putSetting(t, adminRolesKey, `["organizers"]`)
+	f.run(testOrganizerID, testChannelID, "!help")
+	if !strings.Contains(f.Sent[0].Content, "`!setgroup <urlname>`") {
+		t.Errorf("help for an allowed role is missing admin commands:\n%v", formatSent(f.Sent))
+	}
+	// One channel lookup is for the prefix, the other for permissions
+	want := map[string]int{"Channel": 2, "UserChannelPermissions": 1, "GuildMember": 1}
+	if !reflect.DeepEqual(f.Lookups, want) {
+		t.Errorf("looked up %v, want %v", f.Lookups, want)
+	}
+}
+
+func TestCommandsDocumented(t *testing.T) {
+	t.Parallel()
+	for _, cmd := range router.Commands() {
+		if cmd.Description == "" {
+			t.Errorf("%v has no description", cmd.Name)
+		}
+		if len(cmd.Examples) == 0 {
+			t.Errorf("%v has no examples", cmd.Name)
+		}
+	}
+}
@@ -0,0 +1,437 @@
+package main
+
+import (
+	"context"
+	"github.com/jaredkotoff/meetup-bot/meetup"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// Messages for the public, upcoming events in testdata/meetup
+const (
+	genericsSummary = "`Go Night: Generics` - Tue Jun 4 6:30 PM UTC-05:00\n" +
+		"At: `Braintree` - 222 W Merchandise Mart Plaza Chicago, IL 60654\n" +
+		"https://www.meetup.com/golang-chicago/events/250000001/"
+	genericsDescription = "\n\nThree talks on **generics** in practice:\n\n• Constraints & type sets\n" +
+		"• Type inference\n\nFood from Pizza Place (https://www.example.com/pizza)."
+	studyGroupSummary = "`Online Study Group` - Tue Jun 25 6:30 PM UTC-05:00\n" +
+		"https://www.meetup.com/golang-chicago/events/250000004/"
+	profilingSummary = "`Go Night: Profiling` - Tue Jul 2 6:30 PM UTC-05:00\n" +
+		"At: `Braintree` - 222 W Merchandise Mart Plaza Chicago, IL 60654\n" +
+		"https://www.meetup.com/golang-chicago/events/250000005/"
+	hackNightSummary = "`Rust Hack Night` - Sun Jun 9 7:00 PM UTC-05:00\n" +
+		"At: `Mobile Makers` - 35 E Wacker Dr Chicago, IL 60601\n" +
+		"https://www.meetup.com/chicago-rust/events/260000001/"
+)
+
+// following sets up a fake Meetup server and follows groups with the log
+// channel set
+func following(groups string) func(t *testing.T, f *fakeSession) {
+	return func(t *testing.T, f *fakeSession) {
+		setupMeetup(t, f)
+		f.putSetting(t, logChannelKey, testLogID)
+		if groups != "" {
+			f.putSetting(t, groupsKey, groups)
+		}
+	}
+}
+
+func TestMeetupSetGroup(t *testing.T) {
+	t.Parallel()
+	runCommandTests(t, []commandTest{
+		{
+			name:     "valid group",
+			setup:    following(""),
+			commands: []string{"!setgroup golang-chicago"},
+			want: []sentMessage{
+				reply("Group url now set to: golang-chicago\n"),
+				logged("admin set the group to `golang-chicago`"),
+			},
+		},
+		{
+			name:     "replaces followed groups",
+			setup:    following(`["chicago-rust"]`),
+			commands: []string{"!setgroup golang-chicago", "!groups"},
+			want:     []sentMessage{reply("This server follows:\n * `golang-chicago`")},
+		},
+		{
+			name:     "unknown group",
+			setup:    following(""),
+			commands: []string{"!setgroup golang-atlantis"},
+			want:     []sentMessage{reply("Invalid group urlname: Invalid group urlname")},
+		},
+	})
+}
+
+func TestMeetupAddGroup(t *testing.T) {
+	t.Parallel()
+	runCommandTests(t, []commandTest{
+		{
+			name:     "add",
+			setup:    following(`["golang-chicago"]`),
+			commands: []string{"!addgroup chicago-rust", "!groups"},
+			want:     []sentMessage{reply("This server follows:\n * `golang-chicago`\n * `chicago-rust`")},
+		},
+		{
+			name:     "add with channel",
+			setup:    following(`["golang-chicago"]`),
+			commands: []string{"!addgroup chicago-rust <#announcements>"},
+			want: []sentMessage{
+				reply("Following `chicago-rust`, announced in <#announcements>"),
+				logged("admin added the group `chicago-rust`, announced in <#announcements>"),
+			},
+		},
+		{
+			name:     "already followed",
+			setup:    following(`["golang-chicago"]`),
+			commands: []string{"!addgroup GOLANG-CHICAGO", "!groups"},
+			want:     []sentMessage{reply("This server follows:\n * `golang-chicago`")},
+		},
+		{
+			name:     "unknown group",
+			setup:    following(`["golang-chicago"]`),
+			commands: []string{"!addgroup golang-atlantis"},
+			want:     []sentMessage{reply("Invalid group urlname: Invalid group urlname")},
+		},
+		{
+			name:     "voice channel",
+			setup:    following(""),
+			commands: []string{"!addgroup chicago-rust <#voice>"},
+			want:     []sentMessage{reply("<#voice> isn't a text channel")},
+		},
+	})
+}
+
+func TestMeetupNextEvent(t *testing.T) {
+	t.Parallel()
+	runCommandTests(t, []commandTest{
+		{
+			name:     "next public event",
+			setup:    following(`["golang-chicago"]`),
+			author:   testUserID,
+			commands: []string{"!nextevent"},
+			want:     []sentMessage{reply("Next event: " + genericsSummary + genericsDescription)},
+		},
+		{
+			name:     "labeled with several groups",
+			setup:    following(`["chicago-rust","golang-chicago"]`),
+			author:   testUserID,
+			commands: []string{"!next"},
+			want:     []sentMessage{reply("Next event: [Chicago Go Meetup] " + genericsSummary + genericsDescription)},
+		},
+		{
+			name: "custom template",
+			setup: func(t *testing.T, f *fakeSession) {
+				following(`["chicago-rust"]`)(t, f)
+				f.putSetting(t, templatesKey, `{"next":"{{.Event.Name}} with {{.Event.YesRSVPCount}} going"}`)
+			},
+			author:   testUserID,
+			commands: []string{"!nextevent"},
+			want:     []sentMessage{reply("Rust Hack Night with 12 going")},
+		},
+		{
+			name: "timezone",
+			setup: func(t *testing.T, f *fakeSession) {
+				following(`["chicago-rust"]`)(t, f)
+				f.putSetting(t, timezoneKey, "UTC")
+				f.putSetting(t, templatesKey, `{"next":"{{.Time}}"}`)
+			},
+			author:   testUserID,
+			commands: []string{"!nextevent"},
+			want:     []sentMessage{reply("Mon Jun 10 12:00 AM UTC")},
+		},
+		{
+			name:     "no public events",
+			setup:    following(`["golang-nyc"]`),
+			author:   testUserID,
+			commands: []string{"!nextevent"},
+			want:     []sentMessage{reply("No future, public events found")},
+		},
+	})
+}
+
+func TestMeetupGetEvents(t *testing.T) {
+	t.Parallel()
+	runCommandTests(t, []commandTest{
+		{
+			name:     "first page",
+			setup:    following(`["golang-chicago"]`),
+			author:   testUserID,
+			commands: []string{"!getevents 2"},
+			want: []sentMessage{reply("Upcoming events for `golang-chicago` (1-2):\n\n" +
+				"**1.** " + genericsSummary + "\n42 going, 3 on the waitlist\n\n" +
+				"**2.** " + studyGroupSummary + "\n17 going\n\n" +
+				"More: `!getevents 2 2`")},
+		},
+		{
+			name:     "last page",
+			setup:    following(`["golang-chicago"]`),
+			author:   testUserID,
+			commands: []string{"!getevents 2 2"},
+			want: []sentMessage{reply("Upcoming events for `golang-chicago` (3-3):\n\n" +
+				"**3.** " + profilingSummary + "\n25 going")},
+		},
+		{
+			name: "window full of cancelled events",
+			setup: func(t *testing.T, f *fakeSession) {
+				fm := setupMeetup(t, f)
+				f.putSetting(t, groupsKey, `["golang-chicago"]`)
+				var cancelled []meetup.Event
+				for i := 0; i < pollPageSize; i++ {
+					cancelled = append(cancelled, meetup.Event{
+						ID: "cancelled" + strconv.Itoa(i), Name: "Cancelled", Status: "cancelled", Visibility: "public",
+					})
+				}
+				fm.setEvents("golang-chicago", append(cancelled, fm.getEvents("golang-chicago")...))
+			},
+			author:   testUserID,
+			commands: []string{"!getevents 2"},
+			want: []sentMessage{reply("Upcoming events for `golang-chicago` (1-2):\n\n" +
+				"**1.** " + genericsSummary + "\n42 going, 3 on the waitlist\n\n" +
+				"**2.** " + studyGroupSummary + "\n17 going\n\n" +
+				"More: `!getevents 2 2`")},
+		},
+		{
+			name:     "past the end",
+			setup:    following(`["golang-chicago"]`),
+			author:   testUserID,
+			commands: []string{"!getevents 5 2"},
+			want:     []sentMessage{reply("No more upcoming events")},
+		},
+		{
+			name:     "several groups",
+			setup:    following(`["golang-chicago","chicago-rust"]`),
+			author:   testUserID,
+			commands: []string{"!getevents 2"},
+			want: []sentMessage{reply("Upcoming events for `golang-chicago`, `chicago-rust` (1-2):\n\n" +
+				"**1.** [Chicago Go Meetup] " + genericsSummary + "\n42 going, 3 on the waitlist\n\n" +
+				"**2.** [Chicago Rust Meetup] " + hackNightSummary + "\n12 going\n\n" +
+				"More: `!getevents 2 2`")},
+		},
+		{
+			name:     "group removed from Meetup",
+			setup:    following(`["golang-atlantis"]`),
+			author:   testUserID,
+			commands: []string{"!getevents"},
+			want:     []sentMessage{reply("meetup: Invalid group urlname")},
+		},
+		{
+			name: "rate limited",
+			setup: func(t *testing.T, f *fakeSession) {
+				fm := setupMeetup(t, f)
+				f.putSetting(t, groupsKey, `["chicago-rust"]`)
+				f.bot.Meetup.Throttle.MaxRetries = 1
+				fm.throttle(2)
+			},
+			author:   testUserID,
+			commands: []string{"!getevents"},
+			want:     []sentMessage{reply("meetup: Credentials have been throttled")},
+		},
+	})
+}
+
+func TestMeetupRetriesThrottled(t *testing.T) {
+	t.Parallel()
+	f := setupTest(t)
+	fm := setupMeetup(t, f)
+	f.putSetting(t, groupsKey, `["chicago-rust"]`)
+	fm.throttle(2)
+
+	f.run(testUserID, testChannelID, "!nextevent")
+	want := []sentMessage{reply("Next event: " + hackNightSummary + "\n\nBring a laptop.")}
+	if !reflect.DeepEqual(f.Sent, want) {
+		t.Errorf("sent:\n%v\nwant:\n%v", formatSent(f.Sent), formatSent(want))
+	}
+	if n := fm.requestCount(); n != 3 {
+		t.Errorf("made %v requests, want 3", n)
+	}
+}
+
+func TestMeetupRejectsBadKey(t *testing.T) {
+	t.Parallel()
+	f := setupTest(t)
+	setupMeetup(t, f)
+	f.bot.Meetup.APIKey = "wrong"
+
+	_, err := f.bot.Meetup.Group(context.Background(), "golang-chicago")
+	if err == nil || err.Error() != "meetup: Invalid credentials" {
+		t.Errorf("got error %v, want meetup: Invalid credentials", err)
+	}
+}
+
+func TestPollGuild(t *testing.T) {
+	t.Parallel()
+	f := setupTest(t)
+	fm := setupMeetup(t, f)
+	f.putSetting(t, groupsKey, `["golang-chicago"]`)
+	f.putSetting(t, announceChannelKey, "announcements")
+
+	poll := func(step string, want ...sentMessage) {
+		f.Sent = nil
+		if err := f.bot.pollGuild(context.Background(), f, testGuildID); err != nil {
+			t.Fatalf("%v: %s", step, err)
+		}
+		if !reflect.DeepEqual(f.Sent, want) {
+			t.Errorf("%v sent:\n%v\nwant:\n%v", step, formatSent(f.Sent), formatSent(want))
+		}
+	}
+	announced := func(content string) sentMessage {
+		return sentMessage{"announcements", content}
+	}
+
+	// Existing events are recorded without being announced
+	poll("first poll")
+	poll("unchanged")
+
+	events := fm.getEvents("golang-chicago")
+	added := events[0]
+	added.ID = "250000006"
+	added.Name = "Go Night: Fuzzing"
+	added.Link = "https://www.meetup.com/golang-chicago/events/250000006/"
+	private := events[1]
+	private.ID = "250000007"
+	fm.setEvents("golang-chicago", append(events, added, private))
+	poll("new events", announced("New event posted: `Go Night: Fuzzing` - Tue Jun 4 6:30 PM UTC-05:00\n"+
+		"At: `Braintree` - 222 W Merchandise Mart Plaza Chicago, IL 60654\n"+
+		"https://www.meetup.com/golang-chicago/events/250000006/"))
+
+	events = fm.getEvents("golang-chicago")
+	events[0].Time += int64(time.Hour / time.Millisecond)
+	events[0].Updated += 1000
+	events[0].Venue.Name = ""
+	fm.setEvents("golang-chicago", events)
+	poll("changed", announced("Event changed: `Go Night: Generics`\n```diff\n"+
+		"- Time: Tue Jun 4 6:30 PM UTC-05:00\n"+
+		"+ Time: Tue Jun 4 7:30 PM UTC-05:00\n"+
+		"- Venue: Braintree - 222 W Merchandise Mart Plaza Chicago, IL 60654\n"+
+		"+ Venue: None\n"+
+		"```\nhttps://www.meetup.com/golang-chicago/events/250000001/"))
+
+	events = fm.getEvents("golang-chicago")
+	events[3].Status = "cancelled"
+	events[3].Updated += 1000
+	fm.setEvents("golang-chicago", events)
+	poll("cancelled", announced("Event cancelled: `Online Study Group` - Tue Jun 25 6:30 PM UTC-05:00\n"+
+		"https://www.meetup.com/golang-chicago/events/250000004/"))
+	poll("after cancelling")
+}
+
+func TestPollGuildLongAnnouncement(t *testing.T) {
+	t.Parallel()
+	f := setupTest(t)
+	fm := setupMeetup(t, f)
+	f.putSetting(t, groupsKey, `["golang-chicago"]`)
+	f.putSetting(t, announceChannelKey, "announcements")
+	f.putSetting(t, templatesKey, `{"announce":"{{printf \"%02500d\" 1}}"}`)
+	if err := f.bot.pollGuild(context.Background(), f, testGuildID); err != nil {
+		t.Fatal(err)
+	}
+
+	events := fm.getEvents("golang-chicago")
+	added := events[0]
+	added.ID = "250000006"
+	fm.setEvents("golang-chicago", append(events, added))
+	for i := 0; i < 2; i++ {
+		if err := f.bot.pollGuild(context.Background(), f, testGuildID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The announcement is split to fit, and isn't retried once it's sent
+	if len(f.Sent) != 2 || len(f.Sent[0].Content)+len(f.Sent[1].Content) != 2500 {
+		t.Errorf("sent:\n%v", formatSent(f.Sent))
+	}
+}
+
+func TestPollGuildUnreachable(t *testing.T) {
+	t.Parallel()
+	f := setupTest(t)
+	fm := setupMeetup(t, f)
+	f.putSetting(t, groupsKey, `["golang-chicago"]`)
+	f.putSetting(t, announceChannelKey, "announcements")
+	f.putSetting(t, logChannelKey, testLogID)
+
+	if err := f.bot.pollGuild(context.Background(), f, testGuildID); err != nil {
+		t.Fatal(err)
+	}
+	f.bot.Meetup.Throttle.MaxRetries = 0
+	fm.throttle(1)
+	if err := f.bot.pollGuild(context.Background(), f, testGuildID); err != nil {
+		t.Fatal(err)
+	}
+	want := []sentMessage{logged("Couldn't check `golang-chicago` for new events: meetup: Credentials have been throttled")}
+	if !reflect.DeepEqual(f.Sent, want) {
+		t.Errorf("sent:\n%v\nwant:\n%v", formatSent(f.Sent), formatSent(want))
+	}
+
+	// Events are still tracked, so nothing is announced again once Meetup
+	// is back
+	f.Sent = nil
+	if err := f.bot.pollGuild(context.Background(), f, testGuildID); err != nil {
+		t.Fatal(err)
+	}
+	if len(f.Sent) != 0 {
+		t.Errorf("sent after recovering:\n%v", formatSent(f.Sent))
+	}
+}
+
+func TestSendReminders(t *testing.T) {
+	t.Parallel()
+	f := setupTest(t)
+	setupMeetup(t, f)
+	f.putSetting(t, groupsKey, `["golang-chicago"]`)
+	f.putSetting(t, announceChannelKey, "announcements")
+	f.putSetting(t, remindersKey, `["1d","1h"]`)
+	if err := f.bot.pollGuild(context.Background(), f, testGuildID); err != nil {
+		t.Fatal(err)
+	}
+
+	start := msToTime(1906846200000)
+	remind := func(step string, now time.Time, want ...sentMessage) {
+		f.Sent = nil
+		if err := f.bot.sendReminders(f, testGuildID, now); err != nil {
+			t.Fatalf("%v: %s", step, err)
+		}
+		if !reflect.DeepEqual(f.Sent, want) {
+			t.Errorf("%v sent:\n%v\nwant:\n%v", step, formatSent(f.Sent), formatSent(want))
+		}
+	}
+
+	remind("too early", start.Add(-25*time.Hour))
+	remind("a day before", start.Add(-24*time.Hour+30*time.Second),
+		sentMessage{"announcements", "Starting in 1 day: " + genericsSummary})
+	remind("already sent", start.Add(-23*time.Hour))
+	remind("an hour before", start.Add(-time.Hour),
+		sentMessage{"announcements", "Starting in 1 hour: " + genericsSummary})
+	remind("started", start.Add(time.Minute))
+}
+
+func TestRemindersOnly(t *testing.T) {
+	t.Parallel()
+	f := setupTest(t)
+	setupMeetup(t, f)
+	f.putSetting(t, groupsKey, `["golang-chicago"]`)
+	f.putSetting(t, remindersKey, `["1d"]`)
+	f.run(testAdminID, testChannelID, "!setchannel reminders <#general>")
+
+	// Without an announcement channel events are tracked but not announced
+	for i := 0; i < 2; i++ {
+		f.Sent = nil
+		if err := f.bot.pollGuild(context.Background(), f, testGuildID); err != nil {
+			t.Fatal(err)
+		}
+		if len(f.Sent) != 0 {
+			t.Errorf("poll %v sent:\n%v", i, formatSent(f.Sent))
+		}
+	}
+
+	start := msToTime(1906846200000)
+	if err := f.bot.sendReminders(f, testGuildID, start.Add(-24*time.Hour+30*time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	want := []sentMessage{{testChannelID, "Starting in 1 day: " + genericsSummary}}
+	if !reflect.DeepEqual(f.Sent, want) {
+		t.Errorf("sent:\n%v\nwant:\n%v", formatSent(f.Sent), formatSent(want))
+	}
+}
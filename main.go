@@ -1,28 +1,63 @@
 package main
 
 import (
-	"encoding/json"
+	"context"
 	"flag"
 	"fmt"
 	"github.com/boltdb/bolt"
 	"github.com/bwmarrin/discordgo"
-	"io/ioutil"
+	"github.com/jaredkotoff/meetup-bot/meetup"
 	"log"
+	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
+	"stathat.com/c/jconfig"
+	"syscall"
+	"time"
 )
 
-// hostname for meetup.com's api
-const hostname = "https://api.meetup.com/"
+// router holds every command the bot responds to
+var router = NewRouter()
 
-var (
-	// BotID of the user account
-	BotID string
-	// config for the bots settings
-	config *Config
-	// db for dynamic settings per guild
-	db *bolt.DB
-)
+// Bot is the state shared by the bot's handlers and background jobs. Tests
+// build their own so they don't share any.
+type Bot struct {
+	// Context is handed to commands. It's cancelled once shutdown gives up
+	// waiting for them, cutting short any still waiting on Meetup.
+	Context context.Context
+	// ID of the bot's user account
+	ID string
+	// Config is the bot's settings
+	Config *Config
+	// Store keeps dynamic settings per guild
+	Store Store
+	// Meetup makes all requests to meetup.com
+	Meetup *meetup.Client
+	// Prefixes caches the prefixes guilds have set
+	Prefixes *prefixCache
+	// Status is what the health and status endpoints report
+	Status *botStatus
+	// Stats reports usage counts, nil unless StatHatKey is set
+	Stats *statReporter
+	// Work tracks running commands and jobs so shutdown can wait for them
+	Work *tracker
+}
+
+// newBot creates a bot for the config, which must be valid, keeping guild
+// settings in store
+func newBot(cfg *Config, store Store) *Bot {
+	b := &Bot{
+		Context:  context.Background(),
+		Config:   cfg,
+		Store:    store,
+		Prefixes: newPrefixCache(),
+		Status:   newBotStatus(),
+		Work:     &tracker{},
+	}
+	b.Meetup = b.newMeetupClient()
+	return b
+}
 
 // Config stores the settings for the bot
 type Config struct {
@@ -30,64 +65,70 @@ type Config struct {
 	Email    string `json:"email"`
 	Password string `json:"password"`
 	Token    string `json:"token"`
+	// PollInterval is how often groups are checked for new events, e.g. "10m"
+	PollInterval string `json:"pollinterval"`
+	// CacheTTL is how long Meetup responses are reused before revalidating
+	CacheTTL string `json:"cachettl"`
+	// PersistCache keeps cached Meetup responses in the bolt db
+	PersistCache bool `json:"persistcache"`
+	// PurgeOnLeave deletes a guild's settings when the bot is removed from
+	// it instead of archiving them
+	PurgeOnLeave bool `json:"purgeonleave"`
+	// MeetupURL is the root of the Meetup API, e.g. to point the bot at a
+	// local fake while testing
+	MeetupURL string `json:"meetupurl"`
+	// Prefix commands start with on servers that haven't set their own
+	Prefix string `json:"prefix"`
+	// HTTPAddr is where the health and status endpoints are served, e.g.
+	// ":8080". They are off when empty.
+	HTTPAddr string `json:"httpaddr"`
+	// StatHatKey is the EZ key usage counts are reported to StatHat with.
+	// Reporting is off when empty.
+	StatHatKey string `json:"stathatkey"`
 }
 
 // Validate the config settings to ensure essential parameters are set
 func (cfg Config) Validate() error {
-	if config.APIKey == "" {
+	if cfg.APIKey == "" {
 		return fmt.Errorf("Missing Meetup APIKey")
 	}
-	if config.Token == "" {
-		if config.Email == "" || config.Password == "" {
+	if cfg.Token == "" {
+		if cfg.Email == "" || cfg.Password == "" {
 			return fmt.Errorf("Missing Discord Token or Email and Password")
 		}
 	}
+	if _, err := time.ParseDuration(cfg.PollInterval); err != nil {
+		return fmt.Errorf("Invalid PollInterval: %s", err)
+	}
+	if _, err := time.ParseDuration(cfg.CacheTTL); err != nil {
+		return fmt.Errorf("Invalid CacheTTL: %s", err)
+	}
+	if u, err := url.Parse(cfg.MeetupURL); err != nil || u.Scheme == "" || u.Host == "" {
+		return fmt.Errorf("Invalid MeetupURL: %v", cfg.MeetupURL)
+	}
+	if cfg.Prefix == "" {
+		return fmt.Errorf("Missing Prefix")
+	}
+	if problem := validatePrefix(cfg.Prefix); problem != "" {
+		return fmt.Errorf("Invalid Prefix %q: %v", cfg.Prefix, problem)
+	}
 	return nil
 }
 
-// Event is a single event from meetup.com
-type Event struct {
-	ID            string `json:"id"`
-	Name          string `json:"name"`
-	Status        string `json:"status"`
-	Time          int64  `json:"time"`
-	Updated       int64  `json:"updated"`
-	UTCOffset     int64  `json:"utc_offset"`
-	WaitlistCount int    `json:"waitlist_count"`
-	YesRSVPCount  int    `json:"yes_rsvp_count"`
-	Venue         Venue  `json:"venue"`
-	Link          string `json:"link"`
-	Description   string `json:"description"`
-	Visibility    string `json:"visibility"`
-}
-
-// Venue is a location where events happen
-type Venue struct {
-	ID                   int     `json:"id"`
-	Name                 string  `json:"name"`
-	Lat                  float64 `json:"lat"`
-	Lon                  float64 `json:"lon"`
-	Repinned             bool    `json:"repinned"`
-	Address1             string  `json:"address_1"`
-	Address2             string  `json:"address_2"`
-	City                 string  `json:"city"`
-	Country              string  `json:"country"`
-	LocalizedCountryName string  `json:"localized_country_name"`
-	Zip                  string  `json:"zip"`
-	State                string  `json:"state"`
-}
-
-func init() {
-	config = &Config{}
+// loadConfig reads the config from config.json, the command line and the
+// environment, in that order
+func loadConfig() *Config {
+	config := &Config{
+		PollInterval: "10m",
+		CacheTTL:     "5m",
+		MeetupURL:    meetup.DefaultBaseURL,
+		Prefix:       "!",
+	}
 
 	path := "./config.json"
 	if _, err := os.Stat(path); err == nil {
-		configFile, err := ioutil.ReadFile(path)
-		if err == nil {
-			err = json.Unmarshal(configFile, &config)
-		}
-		if err != nil {
-			log.Fatalf("Error opening config file: %s\n", err.Error())
+		if err := config.loadFile(jconfig.LoadConfig(path)); err != nil {
+			log.Fatalf("Error reading config file: %s\n", err.Error())
 		}
 	}
 
@@ -95,6 +136,14 @@ func init() {
 	flag.StringVar(&config.Email, "e", config.Email, "Account Email")
 	flag.StringVar(&config.Password, "p", config.Password, "Account Password")
 	flag.StringVar(&config.Token, "t", config.Token, "Account Token")
+	flag.StringVar(&config.PollInterval, "i", config.PollInterval, "Event Poll Interval")
+	flag.StringVar(&config.CacheTTL, "c", config.CacheTTL, "Meetup Cache TTL")
+	flag.BoolVar(&config.PersistCache, "persistcache", config.PersistCache, "Persist Meetup Cache")
+	flag.BoolVar(&config.PurgeOnLeave, "purgeonleave", config.PurgeOnLeave, "Purge Settings On Leave")
+	flag.StringVar(&config.MeetupURL, "meetupurl", config.MeetupURL, "Meetup API URL")
+	flag.StringVar(&config.Prefix, "prefix", config.Prefix, "Default Command Prefix")
+	flag.StringVar(&config.HTTPAddr, "httpaddr", config.HTTPAddr, "Status HTTP Address")
+	flag.StringVar(&config.StatHatKey, "stathatkey", config.StatHatKey, "StatHat EZ Key")
 	flag.Parse()
 
 	if APIKey := os.Getenv("APIKey"); APIKey != "" {
@@ -113,20 +162,148 @@ func init() {
 		config.Token = Token
 	}
 
+	if PollInterval := os.Getenv("PollInterval"); PollInterval != "" {
+		config.PollInterval = PollInterval
+	}
+
+	if CacheTTL := os.Getenv("CacheTTL"); CacheTTL != "" {
+		config.CacheTTL = CacheTTL
+	}
+
+	if PersistCache := os.Getenv("PersistCache"); PersistCache != "" {
+		config.PersistCache = PersistCache == "true"
+	}
+
+	if PurgeOnLeave := os.Getenv("PurgeOnLeave"); PurgeOnLeave != "" {
+		config.PurgeOnLeave = PurgeOnLeave == "true"
+	}
+
+	if MeetupURL := os.Getenv("MeetupURL"); MeetupURL != "" {
+		config.MeetupURL = MeetupURL
+	}
+
+	if Prefix := os.Getenv("Prefix"); Prefix != "" {
+		config.Prefix = Prefix
+	}
+
+	if HTTPAddr := os.Getenv("HTTPAddr"); HTTPAddr != "" {
+		config.HTTPAddr = HTTPAddr
+	}
+
+	if StatHatKey := os.Getenv("StatHatKey"); StatHatKey != "" {
+		config.StatHatKey = StatHatKey
+	}
+
 	err := config.Validate()
 	if err != nil {
 		log.Fatal(err.Error())
 	}
+	return config
+}
+
+// loadFile fills in the settings config.json sets, named by their json tags or
+// their environment variables. Ones it leaves out or sets to "" keep their
+// current values.
+func (cfg *Config) loadFile(jc *jconfig.Config) error {
+	strs := []struct {
+		key, env string
+		value    *string
+	}{
+		{"apikey", "APIKey", &cfg.APIKey},
+		{"email", "Email", &cfg.Email},
+		{"password", "Password", &cfg.Password},
+		{"token", "Token", &cfg.Token},
+		{"pollinterval", "PollInterval", &cfg.PollInterval},
+		{"cachettl", "CacheTTL", &cfg.CacheTTL},
+		{"meetupurl", "MeetupURL", &cfg.MeetupURL},
+		{"prefix", "Prefix", &cfg.Prefix},
+		{"httpaddr", "HTTPAddr", &cfg.HTTPAddr},
+		{"stathatkey", "StatHatKey", &cfg.StatHatKey},
+	}
+	for _, setting := range strs {
+		for _, key := range []string{setting.key, setting.env} {
+			var value string
+			if err := configType(key, "string", func() { value = jc.GetString(key) }); err != nil {
+				return err
+			}
+			if value != "" {
+				*setting.value = value
+			}
+		}
+	}
+
+	bools := []struct {
+		key, env string
+		value    *bool
+	}{
+		{"persistcache", "PersistCache", &cfg.PersistCache},
+		{"purgeonleave", "PurgeOnLeave", &cfg.PurgeOnLeave},
+	}
+	for _, setting := range bools {
+		for _, key := range []string{setting.key, setting.env} {
+			var value bool
+			if err := configType(key, "true or false", func() { value = jc.GetBool(key) }); err != nil {
+				return err
+			}
+			if value {
+				*setting.value = true
+			}
+		}
+	}
+	return nil
+}
+
+// configType runs get, which reads key with one of jconfig's getters, turning
+// the panic they raise for a value of another type into an error
+func configType(key, kind string, get func()) (err error) {
+	defer func() {
+		if recover() != nil {
+			err = fmt.Errorf("%v must be %v", key, kind)
+		}
+	}()
+	get()
+	return nil
+}
+
+// newMeetupClient creates a Meetup client from the bot's config, which must
+// be valid
+func (b *Bot) newMeetupClient() *meetup.Client {
+	client := meetup.NewClient(b.Config.APIKey)
+	client.BaseURL = b.Config.MeetupURL
+	cacheTTL, _ := time.ParseDuration(b.Config.CacheTTL)
+	client.Cache = meetup.NewCache(cacheTTL)
+	client.OnRequest = b.recordMeetupRequest
+	return client
 }
 
 func main() {
+	config := loadConfig()
+
 	// Open database
-	var err error
-	db, err = bolt.Open("settings.db", 0600, nil)
+	db, err := bolt.Open("settings.db", 0600, nil)
 	if err != nil {
 		log.Fatalf("Error opening bolt db: %s\n", err.Error())
 	}
-	defer db.Close()
+
+	// Upgrade settings from older versions of the bot
+	if err := migrate(db, "settings.db"); err != nil {
+		log.Fatalf("Error migrating bolt db: %s\n", err.Error())
+	}
+	bot := newBot(config, newBoltStore(db))
+	// Background jobs are cancelled as soon as shutdown starts, while running
+	// commands get until the shutdown timeout to finish and reply
+	ctx, cancel := context.WithCancel(context.Background())
+	commandCtx, cancelCommands := context.WithCancel(context.Background())
+	bot.Context = commandCtx
+	// Reporting starts before any handlers or jobs that count things
+	if config.StatHatKey != "" {
+		bot.Stats = newStatReporter(newStathatSink(config.StatHatKey))
+		bot.Stats.Start(ctx, bot.Work, statFlushInterval)
+	}
+
+	if config.PersistCache {
+		bot.Meetup.Cache.Store = &boltCacheStore{db: db}
+	}
 
 	// Create a new Discord session using the provided login information.
 	dg, err := discordgo.New(config.Email, config.Password, config.Token)
@@ -142,7 +319,7 @@ func main() {
 	}
 
 	// Store the account ID for later use.
-	BotID = u.ID
+	bot.ID = u.ID
 
 	// Get all the guilds the bot is in
 	guilds, err := dg.UserGuilds()
@@ -151,31 +328,68 @@ func main() {
 	}
 
 	// Make sure a bucket exists for each guild
-	db.Update(func(tx *bolt.Tx) error {
-		for _, guild := range guilds {
-			_, err := tx.CreateBucket([]byte(guild.ID))
-			if err != nil {
-				return fmt.Errorf("create bucket: %s", err)
-			}
+	for _, guild := range guilds {
+		if _, err := bot.Store.EnsureGuild(guild.ID); err != nil {
+			log.Fatalf("Error creating bucket for guild %v: %s\n", guild.ID, err.Error())
 		}
-		return nil
-	})
+	}
 
 	// Register messageCreate as a callback for the messageCreate events.
-	dg.AddHandler(messageCreate)
+	dg.AddHandler(bot.messageCreate)
+	// Keep guild buckets in step with the guilds the bot is in
+	dg.AddHandler(bot.guildCreate)
+	dg.AddHandler(bot.guildDelete)
+	// Track the websocket for the health endpoints
+	dg.AddHandler(bot.sessionConnect)
+	dg.AddHandler(bot.sessionDisconnect)
+
+	var statusServer *http.Server
+	if config.HTTPAddr != "" {
+		statusServer = bot.startStatusServer(config.HTTPAddr)
+	}
 
 	// Open the websocket and begin listening.
-	dg.Open()
+	if err := dg.Open(); err != nil {
+		log.Fatalf("Error opening Discord session: %s\n", err.Error())
+	}
+
+	// Start announcing new events in the background
+	interval, _ := time.ParseDuration(config.PollInterval)
+	bot.startPoller(ctx, dg, interval)
+	bot.startReminders(ctx, dg)
+	bot.Status.setReady(true)
 
 	fmt.Println("Meetup Bot is now running.  Press CTRL-C to exit.")
 
+	// Heroku stops dynos with SIGTERM
 	c := make(chan os.Signal, 1)
-	signal.Notify(c, os.Interrupt)
+	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
 
 	// Block until a signal is received.
 	s := <-c
-	// Close the websocket
-	dg.Close()
 	fmt.Println("Got signal:", s)
-	return
+	go func() {
+		<-c
+		log.Println("Got a second signal, exiting without waiting")
+		os.Exit(1)
+	}()
+
+	// Stop the background jobs and let running commands and announcements
+	// finish before closing the websocket and database under them
+	bot.Status.setReady(false)
+	cancel()
+	if !bot.Work.Close(shutdownTimeout) {
+		log.Printf("Gave up waiting for running work after %v\n", shutdownTimeout)
+	}
+	cancelCommands()
+	if err := dg.Close(); err != nil {
+		log.Printf("Error closing Discord session: %s\n", err.Error())
+	}
+	// The status endpoints read the db, so they stop first
+	if statusServer != nil {
+		statusServer.Close()
+	}
+	if err := db.Close(); err != nil {
+		log.Printf("Error closing bolt db: %s\n", err.Error())
+	}
 }
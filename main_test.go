@@ -0,0 +1,42 @@
+package main
+
+import (
+	"stathat.com/c/jconfig"
+	"testing"
+)
+
+func TestConfigLoadFile(t *testing.T) {
+	t.Parallel()
+	cfg := &Config{PollInterval: "10m", Prefix: "!"}
+	jc := jconfig.LoadConfigString(`{
+		"apikey": "key",
+		"Token": "token",
+		"pollinterval": "",
+		"persistcache": true,
+		"stathatkey": "stats@example.com"
+	}`)
+	if err := cfg.loadFile(jc); err != nil {
+		t.Fatal(err)
+	}
+	want := Config{
+		APIKey:       "key",
+		Token:        "token",
+		PollInterval: "10m",
+		PersistCache: true,
+		Prefix:       "!",
+		StatHatKey:   "stats@example.com",
+	}
+	if *cfg != want {
+		t.Errorf("loaded %+v, want %+v", *cfg, want)
+	}
+}
+
+func TestConfigLoadFileWrongType(t *testing.T) {
+	t.Parallel()
+	for _, file := range []string{`{"stathatkey": 12}`, `{"PurgeOnLeave": "yes"}`} {
+		cfg := &Config{}
+		if err := cfg.loadFile(jconfig.LoadConfigString(file)); err == nil {
+			t.Errorf("loaded %v without an error", file)
+		}
+	}
+}
@@ -0,0 +1,363 @@
+package main
+
+import (
+	"html"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// markdownMarkers are the Discord markdown markers inline HTML tags become
+var markdownMarkers = map[string]string{
+	"b":      "**",
+	"strong": "**",
+	"i":      "*",
+	"em":     "*",
+	"u":      "__",
+	"ins":    "__",
+	"s":      "~~",
+	"strike": "~~",
+	"del":    "~~",
+	"code":   "`",
+}
+
+// blockTags are the HTML tags that start on a new paragraph
+var blockTags = map[string]bool{
+	"p": true, "div": true, "blockquote": true, "section": true, "article": true,
+	"header": true, "footer": true, "table": true, "hr": true,
+}
+
+// markdownEscaper backslash escapes the characters Discord treats as markdown
+var markdownEscaper = strings.NewReplacer(`\`, `\\`, "*", `\*`, "_", `\_`, "~", `\~`, "`", "\\`")
+
+// markdownList tracks a list being converted
+type markdownList struct {
+	ordered bool
+	items   int
+}
+
+// markdownLink tracks a link being converted so its URL can follow its text
+type markdownLink struct {
+	href  string
+	start int
+}
+
+// markdownConverter turns HTML into Discord markdown one tag at a time
+type markdownConverter struct {
+	out []byte
+	// pending holds markers for opened tags, written before the next text so
+	// that they hug it the way Discord needs
+	pending string
+	lists   []markdownList
+	links   []markdownLink
+	// skip is how many script or style tags the converter is inside
+	skip int
+	pre  int
+	code int
+}
+
+// htmlToMarkdown converts HTML, such as Meetup event descriptions, to Discord
+// markdown. Paragraphs, line breaks, links, emphasis, lists and code are kept
+// and every other tag is dropped.
+func htmlToMarkdown(text string) string {
+	c := &markdownConverter{}
+	for len(text) > 0 {
+		i := strings.IndexByte(text, '<')
+		if i < 0 {
+			c.text(html.UnescapeString(text))
+			break
+		}
+		c.text(html.UnescapeString(text[:i]))
+		text = text[i:]
+
+		if strings.HasPrefix(text, "<!--") {
+			end := strings.Index(text, "-->")
+			if end < 0 {
+				break
+			}
+			text = text[end+3:]
+			continue
+		}
+		end := strings.IndexByte(text, '>')
+		if end < 0 || !isTagStart(text[1:]) {
+			// Not a tag, just a stray <
+			c.text("<")
+			text = text[1:]
+			continue
+		}
+		c.tag(text[1:end])
+		text = text[end+1:]
+	}
+
+	lines := strings.Split(string(c.out), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRightFunc(line, unicode.IsSpace)
+	}
+	result := strings.Join(lines, "\n")
+	for strings.Contains(result, "\n\n\n") {
+		result = strings.Replace(result, "\n\n\n", "\n\n", -1)
+	}
+	return strings.TrimSpace(result)
+}
+
+// isTagStart reports whether text following a < starts a tag name
+func isTagStart(text string) bool {
+	text = strings.TrimPrefix(text, "/")
+	return text != "" && (text[0] >= 'a' && text[0] <= 'z' || text[0] >= 'A' && text[0] <= 'Z')
+}
+
+// tag handles the contents of a single tag, e.g. `a href="..."` or `/p`
+func (c *markdownConverter) tag(contents string) {
+	closing := strings.HasPrefix(contents, "/")
+	contents = strings.TrimPrefix(contents, "/")
+	fields := strings.Fields(contents)
+	if len(fields) == 0 {
+		return
+	}
+	name := strings.ToLower(strings.TrimRight(fields[0], "/"))
+
+	if name == "script" || name == "style" {
+		if closing && c.skip > 0 {
+			c.skip--
+		} else if !closing && !strings.HasSuffix(contents, "/") {
+			c.skip++
+		}
+		return
+	}
+	if c.skip > 0 {
+		return
+	}
+
+	switch {
+	case name == "br":
+		c.newlines(1)
+	case name == "pre":
+		c.newlines(1)
+		if closing {
+			if c.pre > 0 {
+				c.pre--
+				c.newlines(1)
+				c.write("```")
+				c.newlines(1)
+			}
+		} else {
+			c.pre++
+			c.write("```")
+			c.newlines(1)
+		}
+	case name == "ul" || name == "ol":
+		if closing {
+			if len(c.lists) > 0 {
+				c.lists = c.lists[:len(c.lists)-1]
+			}
+		} else {
+			c.lists = append(c.lists, markdownList{ordered: name == "ol"})
+		}
+		if len(c.lists) == 0 {
+			c.newlines(2)
+		} else {
+			c.newlines(1)
+		}
+	case name == "li":
+		c.newlines(1)
+		if closing || len(c.lists) == 0 {
+			return
+		}
+		list := &c.lists[len(c.lists)-1]
+		list.items++
+		c.write(strings.Repeat("  ", len(c.lists)-1))
+		if list.ordered {
+			c.write(strconv.Itoa(list.items) + ". ")
+		} else {
+			c.write("• ")
+		}
+	case name == "a":
+		if !closing {
+			c.links = append(c.links, markdownLink{href: tagAttr(contents, "href"), start: len(c.out)})
+			return
+		}
+		if len(c.links) == 0 {
+			return
+		}
+		link := c.links[len(c.links)-1]
+		c.links = c.links[:len(c.links)-1]
+		if link.href == "" || strings.HasPrefix(link.href, "#") {
+			return
+		}
+		switch linkText := strings.TrimSpace(string(c.out[link.start:])); linkText {
+		case "":
+			c.text(link.href)
+		case link.href, markdownEscaper.Replace(link.href):
+		default:
+			// Discord doesn't support [text](url) links in messages
+			c.out = append(c.out, " ("+link.href+")"...)
+		}
+	case len(name) == 2 && name[0] == 'h' && name[1] >= '1' && name[1] <= '6':
+		// Discord has no headings, so they become bold paragraphs
+		if closing {
+			c.marker("**", closing)
+			c.newlines(2)
+		} else {
+			c.newlines(2)
+			c.marker("**", closing)
+		}
+	case blockTags[name]:
+		// Paragraphs inside list items stay on the item's line
+		if len(c.lists) == 0 {
+			c.newlines(2)
+		}
+	case markdownMarkers[name] != "":
+		if name == "code" && c.pre > 0 {
+			return
+		}
+		if name == "code" {
+			if closing && c.code > 0 {
+				c.code--
+			} else if !closing {
+				c.code++
+			}
+		}
+		c.marker(markdownMarkers[name], closing)
+	}
+}
+
+// text writes text found between tags. Outside of pre tags runs of whitespace
+// collapse to a single space and markdown characters are escaped.
+func (c *markdownConverter) text(text string) {
+	if c.skip > 0 || text == "" {
+		return
+	}
+	if c.pre > 0 {
+		c.flush()
+		c.write(strings.Replace(text, "```", "`\u200b``", -1))
+		return
+	}
+
+	if unicode.IsSpace([]rune(text)[0]) {
+		c.space()
+	}
+	words := strings.Fields(text)
+	for i, word := range words {
+		if i > 0 {
+			c.space()
+		}
+		c.flush()
+		// Escaping links would break them, and code is shown as written
+		if c.code > 0 || strings.HasPrefix(word, "http://") || strings.HasPrefix(word, "https://") {
+			c.write(word)
+		} else {
+			c.write(markdownEscaper.Replace(word))
+		}
+	}
+	if len(words) > 0 && unicode.IsSpace([]rune(text)[len([]rune(text))-1]) {
+		c.space()
+	}
+}
+
+// marker opens or closes an inline marker such as **
+func (c *markdownConverter) marker(marker string, closing bool) {
+	if !closing {
+		c.pending += marker
+		return
+	}
+	if strings.HasSuffix(c.pending, marker) {
+		// Nothing was written inside the tag
+		c.pending = strings.TrimSuffix(c.pending, marker)
+		return
+	}
+	// Move the marker before any trailing space so it hugs the text
+	trimmed := strings.TrimRight(string(c.out), " ")
+	trailing := len(c.out) - len(trimmed)
+	c.out = append([]byte(trimmed), marker...)
+	c.out = append(c.out, strings.Repeat(" ", trailing)...)
+}
+
+// flush writes any pending markers
+func (c *markdownConverter) flush() {
+	c.write(c.pending)
+	c.pending = ""
+}
+
+// space writes a single space unless the output already ends in whitespace
+func (c *markdownConverter) space() {
+	if len(c.out) == 0 {
+		return
+	}
+	if last := c.out[len(c.out)-1]; last != ' ' && last != '\n' {
+		c.out = append(c.out, ' ')
+	}
+}
+
+// newlines ends the output with at least n line breaks
+func (c *markdownConverter) newlines(n int) {
+	if c.pre > 0 {
+		n = 1
+	}
+	c.out = []byte(strings.TrimRight(string(c.out), " "))
+	if len(c.out) == 0 {
+		return
+	}
+	have := len(c.out) - len(strings.TrimRight(string(c.out), "\n"))
+	for ; have < n; have++ {
+		c.out = append(c.out, '\n')
+	}
+}
+
+// write appends s to the output as is
+func (c *markdownConverter) write(s string) {
+	c.out = append(c.out, s...)
+}
+
+// tagAttr returns the value of an attribute from the contents of a tag, e.g.
+// `a href="..."`. Attributes are walked one by one, matching names without
+// regard to case, so values that contain the name aren't mistaken for it.
+func tagAttr(contents, name string) string {
+	// Skip the tag name
+	rest := strings.TrimLeftFunc(contents, unicode.IsSpace)
+	i := strings.IndexFunc(rest, unicode.IsSpace)
+	if i < 0 {
+		return ""
+	}
+	rest = rest[i:]
+
+	for {
+		rest = strings.TrimLeftFunc(rest, unicode.IsSpace)
+		if rest == "" {
+			return ""
+		}
+		end := strings.IndexFunc(rest, func(r rune) bool { return unicode.IsSpace(r) || r == '=' })
+		if end < 0 {
+			end = len(rest)
+		}
+		attr := rest[:end]
+		rest = strings.TrimLeftFunc(rest[end:], unicode.IsSpace)
+
+		var value string
+		if strings.HasPrefix(rest, "=") {
+			value, rest = attrValue(strings.TrimLeftFunc(rest[1:], unicode.IsSpace))
+		}
+		if strings.EqualFold(attr, name) {
+			return html.UnescapeString(value)
+		}
+	}
+}
+
+// attrValue splits an attribute value, quoted or not, from the rest of a tag.
+// Values missing their closing quote are empty.
+func attrValue(text string) (string, string) {
+	if text == "" {
+		return "", ""
+	}
+	if quote := text[0]; quote == '"' || quote == '\'' {
+		end := strings.IndexByte(text[1:], quote)
+		if end < 0 {
+			return "", ""
+		}
+		return text[1 : end+1], text[end+2:]
+	}
+	end := strings.IndexFunc(text, func(r rune) bool { return unicode.IsSpace(r) || r == '>' })
+	if end < 0 {
+		end = len(text)
+	}
+	return text[:end], text[end:]
+}
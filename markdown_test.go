@@ -0,0 +1,61 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestHTMLToMarkdown(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name string
+		html string
+		want string
+	}{
+		{"plain text", "Talks and pizza", "Talks and pizza"},
+		{"paragraphs", "<p>First</p><p>Second</p>", "First\n\nSecond"},
+		{"line breaks", "One<br>Two<br/>Three<BR />", "One\nTwo\nThree"},
+		{"whitespace", "<p>\n  Lots   of\n\tspace  </p>\n\n<p> here </p>", "Lots of space\n\nhere"},
+		{"bold and italic", "<b>bold</b>, <strong>strong</strong>, <i>it</i> and <em>em</em>",
+			"**bold**, **strong**, *it* and *em*"},
+		{"nested", "<b><i>both</i></b>", "***both***"},
+		{"markers hug text", "Some<b> bold </b>text", "Some **bold** text"},
+		{"empty markers", "<b></b>Text<i> </i>", "Text"},
+		{"underline and strike", "<u>under</u> <s>gone</s> <del>gone</del>", "__under__ ~~gone~~ ~~gone~~"},
+		{"link", `See <a href="https://example.com/a">the page</a> soon`,
+			"See the page (https://example.com/a) soon"},
+		{"link to itself", `<a href="https://example.com/a_b">https://example.com/a_b</a>`,
+			"https://example.com/a_b"},
+		{"link without text", `<a href='https://example.com/'></a>`, "https://example.com/"},
+		{"anchor", `<a href="#top">Top</a>`, "Top"},
+		{"unquoted href", `<a class=x href=https://example.com/>x</a>`, "x (https://example.com/)"},
+		{"other attribute", `<a data-href="nope" href="https://example.com/">x</a>`, "x (https://example.com/)"},
+		{"name in another value", `<a title="see href=nope" HREF="https://example.com/">x</a>`, "x (https://example.com/)"},
+		// Lowercasing these changes their length in bytes
+		{"growing title", `<p>Hi <a title="ȺȺȺȺȺȺȺȺȺȺ" href=y>link</a></p>`, "Hi link (y)"},
+		{"shrinking title", `<p>Hi <a title="İİİİİİİİİİ" href=y>link</a></p>`, "Hi link (y)"},
+		{"unterminated quote", `<a title="x href=y>link</a>`, "link"},
+		{"list", "<p>Bring:</p><ul><li>A laptop</li><li><p>Snacks</p></li></ul><p>Thanks</p>",
+			"Bring:\n\n• A laptop\n• Snacks\n\nThanks"},
+		{"ordered list", "<ol><li>One</li><li>Two</li></ol>", "1. One\n2. Two"},
+		{"nested list", "<ul><li>Talks<ol><li>Generics</li><li>Fuzzing</li></ol></li><li>Pizza</li></ul>",
+			"• Talks\n  1. Generics\n  2. Fuzzing\n• Pizza"},
+		{"headings", "<h2>Agenda</h2><p>Talks</p>", "**Agenda**\n\nTalks"},
+		{"code", "Run <code>go_test</code>", "Run `go_test`"},
+		{"pre", "<p>Try:</p><pre>go test\n  ./...</pre>", "Try:\n\n```\ngo test\n  ./...\n```"},
+		{"entities", "Fish &amp; chips &lt;3 &quot;yum&quot; &#39;ok&#39;", `Fish & chips <3 "yum" 'ok'`},
+		{"escapes markdown", "snake_case *stars* ~tilde~ `tick`", "snake\\_case \\*stars\\* \\~tilde\\~ \\`tick\\`"},
+		{"leaves urls alone", "Slides at https://example.com/go_slides", "Slides at https://example.com/go_slides"},
+		{"unsupported tags", `<span style="color:red">Red</span> <img src="x.png" alt="x"><font>text</font>`,
+			"Red text"},
+		{"script and style", "<style>p { color: red }</style>Hi<script>alert('x')</script>", "Hi"},
+		{"comments", "Hi<!-- hidden -->there", "Hithere"},
+		{"stray brackets", "1 < 2 and 3 > 2", "1 < 2 and 3 > 2"},
+		{"empty", "", ""},
+	}
+
+	for _, test := range tests {
+		if got := htmlToMarkdown(test.html); got != test.want {
+			t.Errorf("%v: htmlToMarkdown(%q) = %q, want %q", test.name, test.html, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package meetup
+
+import (
+	"sync"
+	"time"
+)
+
+// DefaultKeep is how long a Cache from NewCache keeps entries after they
+// expire, in case they can be revalidated
+const DefaultKeep = time.Hour
+
+// SweepInterval is how often stores drop evictable entries
+const SweepInterval = 10 * time.Minute
+
+// CacheEntry is a cached response body
+type CacheEntry struct {
+	Body    []byte    `json:"body"`
+	ETag    string    `json:"etag"`
+	Expires time.Time `json:"expires"`
+	// Evict is when the entry is no longer worth keeping for revalidation
+	Evict time.Time `json:"evict"`
+}
+
+// Evictable reports whether the entry can be dropped. Entries saved without
+// an Evict time are kept until they expire.
+func (e *CacheEntry) Evictable(now time.Time) bool {
+	if e.Evict.IsZero() {
+		return now.After(e.Expires)
+	}
+	return now.After(e.Evict)
+}
+
+// CacheStore holds cache entries by key. Stores should drop entries once
+// they are Evictable.
+type CacheStore interface {
+	Get(key string) (*CacheEntry, bool)
+	Set(key string, entry *CacheEntry)
+}
+
+// Cache keeps responses for TTL, after which they are revalidated with
+// If-None-Match so unchanged data costs Meetup a 304 instead of a full
+// response. Keys are the request path and query without the API key, so
+// every guild following a group shares the same entries.
+type Cache struct {
+	TTL time.Duration
+	// Keep is how long entries are kept after TTL so they can be revalidated
+	Keep  time.Duration
+	Store CacheStore
+}
+
+// NewCache creates an in-memory cache keeping responses fresh for ttl
+func NewCache(ttl time.Duration) *Cache {
+	return &Cache{
+		TTL:   ttl,
+		Keep:  DefaultKeep,
+		Store: NewMemoryStore(),
+	}
+}
+
+// lookup returns the entry for key, if any, and whether it is still fresh
+func (c *Cache) lookup(key string) (*CacheEntry, bool) {
+	if c == nil {
+		return nil, false
+	}
+	entry, ok := c.Store.Get(key)
+	if !ok {
+		return nil, false
+	}
+	return entry, time.Now().Before(entry.Expires)
+}
+
+// save stores body under key, fresh for the cache's TTL
+func (c *Cache) save(key string, body []byte, etag string) {
+	if c == nil {
+		return
+	}
+	expires := time.Now().Add(c.TTL)
+	c.Store.Set(key, &CacheEntry{
+		Body:    body,
+		ETag:    etag,
+		Expires: expires,
+		Evict:   expires.Add(c.Keep),
+	})
+}
+
+// MemoryStore is a CacheStore kept in process memory
+type MemoryStore struct {
+	mu        sync.RWMutex
+	entries   map[string]*CacheEntry
+	nextSweep time.Time
+}
+
+// NewMemoryStore creates an empty MemoryStore
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{entries: make(map[string]*CacheEntry)}
+}
+
+// Get returns the entry for key, unless it is evictable
+func (m *MemoryStore) Get(key string) (*CacheEntry, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	entry, ok := m.entries[key]
+	if !ok || entry.Evictable(time.Now()) {
+		return nil, false
+	}
+	return entry, true
+}
+
+// Set stores entry under key. Evictable entries are dropped every
+// SweepInterval.
+func (m *MemoryStore) Set(key string, entry *CacheEntry) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.entries[key] = entry
+
+	now := time.Now()
+	if now.Before(m.nextSweep) {
+		return
+	}
+	m.nextSweep = now.Add(SweepInterval)
+	for key, entry := range m.entries {
+		if entry.Evictable(now) {
+			delete(m.entries, key)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package meetup
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCacheEntryEvictable(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		entry CacheEntry
+		want  bool
+	}{
+		{CacheEntry{Expires: now.Add(time.Minute), Evict: now.Add(time.Hour)}, false},
+		{CacheEntry{Expires: now.Add(-time.Minute), Evict: now.Add(time.Hour)}, false},
+		{CacheEntry{Expires: now.Add(-time.Hour), Evict: now.Add(-time.Minute)}, true},
+		// Entries saved before Evict existed go once they expire
+		{CacheEntry{Expires: now.Add(time.Minute)}, false},
+		{CacheEntry{Expires: now.Add(-time.Minute)}, true},
+	}
+	for _, test := range tests {
+		if got := test.entry.Evictable(now); got != test.want {
+			t.Errorf("%+v Evictable() = %v, want %v", test.entry, got, test.want)
+		}
+	}
+}
+
+func TestCacheSave(t *testing.T) {
+	c := &Cache{TTL: time.Minute, Keep: time.Hour, Store: NewMemoryStore()}
+	c.save("key", []byte("body"), `"etag"`)
+	entry, fresh := c.lookup("key")
+	if entry == nil || !fresh || entry.ETag != `"etag"` {
+		t.Fatalf("lookup() = %+v, %v", entry, fresh)
+	}
+	if keep := entry.Evict.Sub(entry.Expires); keep != time.Hour {
+		t.Errorf("entry is kept %v after expiring, want %v", keep, time.Hour)
+	}
+
+	// An expired entry is still returned so it can be revalidated
+	c.TTL = -time.Minute
+	c.save("key", []byte("body"), `"etag"`)
+	if entry, fresh := c.lookup("key"); entry == nil || fresh {
+		t.Errorf("lookup() of an expired entry = %+v, %v", entry, fresh)
+	}
+}
+
+func TestMemoryStoreEviction(t *testing.T) {
+	m := NewMemoryStore()
+	now := time.Now()
+	m.Set("stale", &CacheEntry{Expires: now.Add(-time.Hour), Evict: now.Add(time.Hour)})
+	m.Set("evicted", &CacheEntry{Expires: now.Add(-time.Hour), Evict: now.Add(-time.Minute)})
+	if _, ok := m.Get("stale"); !ok {
+		t.Error("expired entry was dropped before it was evictable")
+	}
+	if _, ok := m.Get("evicted"); ok {
+		t.Error("Get returned an evictable entry")
+	}
+
+	// The next sweep deletes evictable entries
+	m.nextSweep = time.Time{}
+	m.Set("fresh", &CacheEntry{Expires: now.Add(time.Hour)})
+	if len(m.entries) != 2 || m.entries["evicted"] != nil {
+		t.Errorf("store holds %v entries after sweeping, want 2", len(m.entries))
+	}
+}
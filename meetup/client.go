@@ -0,0 +1,185 @@
+// Package meetup is a small client for the parts of the meetup.com API the
+// bot uses.
+package meetup
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultBaseURL is the root of meetup.com's API
+const DefaultBaseURL = "https://api.meetup.com/"
+
+// DefaultTimeout bounds each request made by a client from NewClient
+const DefaultTimeout = 10 * time.Second
+
+// Client makes authenticated requests against the Meetup API
+type Client struct {
+	// BaseURL is the root all API paths are resolved against
+	BaseURL string
+	// APIKey is sent with every request
+	APIKey string
+	// HTTPClient performs the requests. Its Timeout bounds each call.
+	HTTPClient *http.Client
+	// Throttle keeps requests within Meetup's rate limit. Share one between
+	// clients using the same key; nil disables throttling and retries.
+	Throttle *Throttle
+	// Cache holds responses between calls; nil disables caching
+	Cache *Cache
+	// OnRequest is called after every request sent, including retries, e.g.
+	// to record metrics
+	OnRequest RequestHook
+}
+
+// RequestHook is told about a request to endpoint, such as "events", once it
+// completes. status is 0 when no response was received.
+type RequestHook func(endpoint string, status int, elapsed time.Duration)
+
+// NewClient creates a client for meetup.com using apiKey
+func NewClient(apiKey string) *Client {
+	return &Client{
+		BaseURL:    DefaultBaseURL,
+		APIKey:     apiKey,
+		HTTPClient: &http.Client{Timeout: DefaultTimeout},
+		Throttle:   NewThrottle(),
+	}
+}
+
+// Group gets a group by its urlname
+func (c *Client) Group(ctx context.Context, urlName string) (*Group, error) {
+	var group Group
+	err := c.get(ctx, "group", url.PathEscape(urlName), nil, &group)
+	if err != nil {
+		return nil, err
+	}
+	return &group, nil
+}
+
+// Events gets up to page of a group's events, soonest first. status is a
+// comma separated list such as "upcoming,cancelled"; empty uses Meetup's
+// default of upcoming events.
+func (c *Client) Events(ctx context.Context, urlName, status string, page int) ([]Event, error) {
+	query := url.Values{}
+	if status != "" {
+		query.Set("status", status)
+	}
+	if page > 0 {
+		query.Set("page", strconv.Itoa(page))
+	}
+
+	var events []Event
+	err := c.get(ctx, "events", url.PathEscape(urlName)+"/events", query, &events)
+	return events, err
+}
+
+// Event gets a single event of a group by its ID
+func (c *Client) Event(ctx context.Context, urlName, eventID string) (*Event, error) {
+	var event Event
+	err := c.get(ctx, "event", url.PathEscape(urlName)+"/events/"+url.PathEscape(eventID), nil, &event)
+	if err != nil {
+		return nil, err
+	}
+	return &event, nil
+}
+
+// RSVPs gets the RSVPs for one of a group's events
+func (c *Client) RSVPs(ctx context.Context, urlName, eventID string) ([]RSVP, error) {
+	var rsvps []RSVP
+	err := c.get(ctx, "rsvps", url.PathEscape(urlName)+"/events/"+url.PathEscape(eventID)+"/rsvps", nil, &rsvps)
+	return rsvps, err
+}
+
+// get requests path relative to BaseURL and decodes the JSON response into
+// target. Fresh cached responses are used without a request. Non-2xx
+// responses are returned as an *Error. endpoint names the kind of request
+// for OnRequest. Cancelling ctx abandons the request and any waits for the
+// rate limit.
+func (c *Client) get(ctx context.Context, endpoint, path string, query url.Values, target interface{}) error {
+	if query == nil {
+		query = url.Values{}
+	}
+	key := path + "?" + query.Encode()
+	entry, fresh := c.Cache.lookup(key)
+	if fresh {
+		return json.Unmarshal(entry.Body, target)
+	}
+
+	query.Set("key", c.APIKey)
+	req, err := http.NewRequest("GET", strings.TrimSuffix(c.BaseURL, "/")+"/"+path+"?"+query.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	if entry != nil && entry.ETag != "" {
+		req.Header.Set("If-None-Match", entry.ETag)
+	}
+
+	resp, err := c.do(ctx, endpoint, req)
+	if err != nil {
+		// Don't leak the API key into logs or chat through the request URL
+		if urlErr, ok := err.(*url.Error); ok {
+			urlErr.URL = strings.Replace(urlErr.URL, url.QueryEscape(c.APIKey), "REDACTED", -1)
+		}
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified && entry != nil {
+		c.Cache.save(key, entry.Body, entry.ETag)
+		return json.Unmarshal(entry.Body, target)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return parseError(resp)
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(body, target); err != nil {
+		return err
+	}
+	c.Cache.save(key, body, resp.Header.Get("ETag"))
+	return nil
+}
+
+// do sends a request through the throttle, retrying rate limited responses
+// until ctx is done
+func (c *Client) do(ctx context.Context, endpoint string, req *http.Request) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		if err := c.Throttle.Wait(ctx); err != nil {
+			return nil, err
+		}
+		start := time.Now()
+		resp, err := c.HTTPClient.Do(req)
+		if c.OnRequest != nil {
+			status := 0
+			if resp != nil {
+				status = resp.StatusCode
+			}
+			c.OnRequest(endpoint, status, time.Since(start))
+		}
+		if err != nil {
+			return nil, err
+		}
+		c.Throttle.Update(resp.Header)
+
+		if resp.StatusCode != http.StatusTooManyRequests {
+			return resp, nil
+		}
+		delay, retry := c.Throttle.Backoff(attempt, resp.Header)
+		if !retry {
+			return resp, nil
+		}
+		resp.Body.Close()
+		if err := sleep(ctx, delay); err != nil {
+			return nil, err
+		}
+	}
+}
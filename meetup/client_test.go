@@ -0,0 +1,60 @@
+package meetup
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestClientRetry(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) == 1 {
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Write([]byte(`{"urlname":"golang-chicago","name":"Chicago Go"}`))
+	}))
+	defer server.Close()
+
+	c := NewClient("key")
+	c.BaseURL = server.URL
+	c.Throttle.BaseDelay = time.Millisecond
+	group, err := c.Group(context.Background(), "golang-chicago")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if group.Name != "Chicago Go" || atomic.LoadInt32(&requests) != 2 {
+		t.Errorf("got %+v after %v requests", group, requests)
+	}
+}
+
+func TestClientRetryCancel(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.Header().Set("Retry-After", "60")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	c := NewClient("key")
+	c.BaseURL = server.URL
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := c.Group(ctx, "golang-chicago")
+	if err != context.DeadlineExceeded {
+		t.Errorf("Group() = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("cancelled request took %v", elapsed)
+	}
+	if n := atomic.LoadInt32(&requests); n != 1 {
+		t.Errorf("made %v requests, want 1", n)
+	}
+}
@@ -0,0 +1,69 @@
+package meetup
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+// Error is a non-2xx response from the Meetup API
+type Error struct {
+	StatusCode int           `json:"-"`
+	Errors     []ErrorDetail `json:"errors"`
+}
+
+// ErrorDetail is a single problem reported in a Meetup error body
+type ErrorDetail struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Field   string `json:"field"`
+}
+
+// Error returns Meetup's messages, falling back to the HTTP status
+func (e *Error) Error() string {
+	if len(e.Errors) == 0 {
+		return fmt.Sprintf("meetup: %v %v", e.StatusCode, http.StatusText(e.StatusCode))
+	}
+	var messages []string
+	for _, detail := range e.Errors {
+		messages = append(messages, detail.Message)
+	}
+	return "meetup: " + strings.Join(messages, ", ")
+}
+
+// Message returns Meetup's human readable description of the error
+func (e *Error) Message() string {
+	if len(e.Errors) == 0 {
+		return http.StatusText(e.StatusCode)
+	}
+	return e.Errors[0].Message
+}
+
+// IsNotFound reports whether err is a Meetup 404, e.g. for a bad urlname
+func IsNotFound(err error) bool {
+	apiErr, ok := err.(*Error)
+	return ok && apiErr.StatusCode == http.StatusNotFound
+}
+
+// parseError builds an *Error from an error response. Bodies that aren't
+// Meetup's JSON error format still produce an error with the status code.
+func parseError(resp *http.Response) error {
+	apiErr := &Error{StatusCode: resp.StatusCode}
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, 1<<16))
+	if err == nil {
+		// Some endpoints use the older {"code": "", "details": ""} format
+		var legacy struct {
+			Code    string `json:"code"`
+			Details string `json:"details"`
+		}
+		if json.Unmarshal(body, apiErr) != nil || len(apiErr.Errors) == 0 {
+			if json.Unmarshal(body, &legacy) == nil && legacy.Details != "" {
+				apiErr.Errors = []ErrorDetail{{Code: legacy.Code, Message: legacy.Details}}
+			}
+		}
+	}
+	return apiErr
+}
@@ -0,0 +1,81 @@
+package meetup
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseError(t *testing.T) {
+	tests := []struct {
+		status  int
+		body    string
+		details []ErrorDetail
+		err     string
+		message string
+	}{
+		{
+			http.StatusBadRequest,
+			`{"errors":[{"code":"bad_param","message":"Bad page","field":"page"},{"code":"x","message":"Also bad"}]}`,
+			[]ErrorDetail{{"bad_param", "Bad page", "page"}, {"x", "Also bad", ""}},
+			"meetup: Bad page, Also bad",
+			"Bad page",
+		},
+		{
+			http.StatusUnauthorized,
+			`{"code":"not_authorized","details":"Invalid API key"}`,
+			[]ErrorDetail{{Code: "not_authorized", Message: "Invalid API key"}},
+			"meetup: Invalid API key",
+			"Invalid API key",
+		},
+		{
+			http.StatusBadGateway,
+			`<html>Bad Gateway</html>`,
+			nil,
+			"meetup: 502 Bad Gateway",
+			"Bad Gateway",
+		},
+		{
+			http.StatusNotFound,
+			`{"errors":[]}`,
+			nil,
+			"meetup: 404 Not Found",
+			"Not Found",
+		},
+	}
+	for _, test := range tests {
+		err := parseError(&http.Response{
+			StatusCode: test.status,
+			Body:       ioutil.NopCloser(strings.NewReader(test.body)),
+		})
+		apiErr, ok := err.(*Error)
+		if !ok {
+			t.Fatalf("parseError(%q) = %T, want *Error", test.body, err)
+		}
+		if apiErr.StatusCode != test.status || len(apiErr.Errors) != len(test.details) ||
+			len(test.details) > 0 && !reflect.DeepEqual(apiErr.Errors, test.details) {
+			t.Errorf("parseError(%q) = %+v", test.body, apiErr)
+		}
+		if apiErr.Error() != test.err {
+			t.Errorf("parseError(%q).Error() = %q, want %q", test.body, apiErr.Error(), test.err)
+		}
+		if apiErr.Message() != test.message {
+			t.Errorf("parseError(%q).Message() = %q, want %q", test.body, apiErr.Message(), test.message)
+		}
+	}
+}
+
+func TestIsNotFound(t *testing.T) {
+	if !IsNotFound(&Error{StatusCode: http.StatusNotFound}) {
+		t.Error("IsNotFound missed a 404")
+	}
+	if IsNotFound(&Error{StatusCode: http.StatusGone}) {
+		t.Error("IsNotFound matched a 410")
+	}
+	if IsNotFound(errors.New("404")) {
+		t.Error("IsNotFound matched an error that isn't from Meetup")
+	}
+}
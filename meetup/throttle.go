@@ -0,0 +1,132 @@
+package meetup
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Throttle spaces out requests using Meetup's X-RateLimit-* response headers.
+// Once the quota for the current window drops to Reserve, callers block until
+// the window resets. A single Throttle is safe to share between goroutines
+// and clients using the same API key.
+type Throttle struct {
+	// Reserve is how many requests are left unused in each window
+	Reserve int
+	// MaxRetries is how many times a 429 response is retried
+	MaxRetries int
+	// BaseDelay is the first retry delay when Meetup doesn't say how long to
+	// wait. It doubles with every retry.
+	BaseDelay time.Duration
+
+	mu        sync.Mutex
+	known     bool
+	remaining int
+	reset     time.Time
+}
+
+// NewThrottle creates a throttle with conservative defaults
+func NewThrottle() *Throttle {
+	return &Throttle{
+		Reserve:    2,
+		MaxRetries: 3,
+		BaseDelay:  time.Second,
+	}
+}
+
+// Wait blocks until a request may be made or ctx is done, in which case it
+// returns ctx's error. The lock isn't held while waiting, so responses can
+// still Update the throttle.
+func (t *Throttle) Wait(ctx context.Context) error {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for t.known && t.remaining <= t.Reserve {
+		wait := t.reset.Sub(time.Now())
+		if wait <= 0 {
+			// The window has reset but the new quota is unknown until the
+			// next response, so let this request through
+			t.known = false
+			break
+		}
+		t.mu.Unlock()
+		err := sleep(ctx, wait)
+		t.mu.Lock()
+		if err != nil {
+			return err
+		}
+	}
+	if t.known {
+		// Count the request now so concurrent callers see it before the
+		// response headers arrive
+		t.remaining--
+	}
+	return nil
+}
+
+// Update records the rate limit state reported in a response's headers
+func (t *Throttle) Update(header http.Header) {
+	if t == nil {
+		return
+	}
+	remaining, err := strconv.Atoi(header.Get("X-RateLimit-Remaining"))
+	if err != nil {
+		return
+	}
+	// Reset is the number of seconds until the window resets
+	reset, err := strconv.ParseFloat(header.Get("X-RateLimit-Reset"), 64)
+	if err != nil {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.known = true
+	t.remaining = remaining
+	t.reset = time.Now().Add(time.Duration(reset * float64(time.Second)))
+}
+
+// Backoff returns how long to wait before retry attempt (starting at 0) of a
+// request that got a 429, and blocks other callers for the same period. It
+// returns false once MaxRetries is used up.
+func (t *Throttle) Backoff(attempt int, header http.Header) (time.Duration, bool) {
+	if t == nil || attempt >= t.MaxRetries {
+		return 0, false
+	}
+
+	delay := t.BaseDelay << uint(attempt)
+	for _, name := range []string{"Retry-After", "X-RateLimit-Reset"} {
+		if seconds, err := strconv.ParseFloat(header.Get(name), 64); err == nil {
+			if d := time.Duration(seconds * float64(time.Second)); d > delay {
+				delay = d
+			}
+			break
+		}
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.known = true
+	t.remaining = 0
+	if until := time.Now().Add(delay); until.After(t.reset) {
+		t.reset = until
+	}
+	return delay, true
+}
+
+// sleep pauses for d, returning early with ctx's error if ctx is done first
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,149 @@
+package meetup
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// rateHeader builds the headers Meetup sends with the rate limit state
+func rateHeader(remaining, reset string) http.Header {
+	header := http.Header{}
+	header.Set("X-RateLimit-Remaining", remaining)
+	header.Set("X-RateLimit-Reset", reset)
+	return header
+}
+
+func TestThrottleNil(t *testing.T) {
+	var throttle *Throttle
+	if err := throttle.Wait(context.Background()); err != nil {
+		t.Errorf("Wait() = %v", err)
+	}
+	throttle.Update(rateHeader("0", "60"))
+	if _, ok := throttle.Backoff(0, nil); ok {
+		t.Error("a nil throttle retried")
+	}
+}
+
+func TestThrottleWait(t *testing.T) {
+	throttle := &Throttle{Reserve: 1}
+
+	// Nothing is known until the first response
+	start := time.Now()
+	if err := throttle.Wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	throttle.Update(rateHeader("3", "0.05"))
+	for i := 0; i < 2; i++ {
+		if err := throttle.Wait(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed >= 50*time.Millisecond {
+		t.Errorf("waited %v with quota left", elapsed)
+	}
+
+	// The quota is down to the reserve, so the next caller waits for the
+	// window to reset
+	if err := throttle.Wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("waited %v, want the rest of the window", elapsed)
+	}
+}
+
+func TestThrottleWaitUnlocked(t *testing.T) {
+	throttle := &Throttle{}
+	throttle.Update(rateHeader("0", "60"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	waiting := make(chan struct{})
+	go func() {
+		close(waiting)
+		throttle.Wait(ctx)
+	}()
+	<-waiting
+
+	// Responses to other requests can still update the throttle while a
+	// caller waits for the window to reset
+	updated := make(chan struct{})
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		throttle.Update(rateHeader("5", "60"))
+		close(updated)
+	}()
+	select {
+	case <-updated:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Update blocked behind Wait")
+	}
+}
+
+func TestThrottleWaitCancel(t *testing.T) {
+	throttle := &Throttle{}
+	throttle.Update(rateHeader("0", "60"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- throttle.Wait(ctx) }()
+	cancel()
+	select {
+	case err := <-done:
+		if err != context.Canceled {
+			t.Errorf("Wait() = %v, want %v", err, context.Canceled)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Wait ignored the cancelled context")
+	}
+
+	// The lock was released on the way out
+	throttle.Update(rateHeader("5", "60"))
+	if err := throttle.Wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestThrottleBackoff(t *testing.T) {
+	tests := []struct {
+		attempt int
+		header  http.Header
+		want    time.Duration
+		ok      bool
+	}{
+		{0, nil, time.Second, true},
+		{1, nil, 2 * time.Second, true},
+		{2, nil, 4 * time.Second, true},
+		{3, nil, 0, false},
+		// Meetup's own delay wins when it is longer
+		{0, http.Header{"Retry-After": {"30"}}, 30 * time.Second, true},
+		{0, rateHeader("0", "1.5"), 1500 * time.Millisecond, true},
+		{2, http.Header{"Retry-After": {"2"}}, 4 * time.Second, true},
+		// Retry-After is preferred over the rate limit reset
+		{0, http.Header{"Retry-After": {"5"}, "X-Ratelimit-Reset": {"60"}}, 5 * time.Second, true},
+		{0, http.Header{"Retry-After": {"soon"}}, time.Second, true},
+	}
+	for _, test := range tests {
+		throttle := &Throttle{MaxRetries: 3, BaseDelay: time.Second}
+		got, ok := throttle.Backoff(test.attempt, test.header)
+		if got != test.want || ok != test.ok {
+			t.Errorf("Backoff(%v, %v) = %v, %v, want %v, %v", test.attempt, test.header, got, ok, test.want, test.ok)
+		}
+	}
+}
+
+func TestThrottleBackoffBlocks(t *testing.T) {
+	throttle := &Throttle{MaxRetries: 1, BaseDelay: 50 * time.Millisecond}
+	start := time.Now()
+	if _, ok := throttle.Backoff(0, nil); !ok {
+		t.Fatal("Backoff gave up on the first retry")
+	}
+	if err := throttle.Wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("Wait returned after %v, during the backoff", elapsed)
+	}
+}
@@ -0,0 +1,71 @@
+package meetup
+
+// Group is a meetup.com group
+type Group struct {
+	ID          int    `json:"id"`
+	Name        string `json:"name"`
+	URLName     string `json:"urlname"`
+	Link        string `json:"link"`
+	Description string `json:"description"`
+	City        string `json:"city"`
+	State       string `json:"state"`
+	Country     string `json:"country"`
+	Timezone    string `json:"timezone"`
+	Members     int    `json:"members"`
+	Who         string `json:"who"`
+}
+
+// EventGroup is the summary of the hosting group included with each event
+type EventGroup struct {
+	ID      int    `json:"id"`
+	Name    string `json:"name"`
+	URLName string `json:"urlname"`
+}
+
+// Event is a single event from meetup.com
+type Event struct {
+	ID            string     `json:"id"`
+	Name          string     `json:"name"`
+	Status        string     `json:"status"`
+	Time          int64      `json:"time"`
+	Updated       int64      `json:"updated"`
+	UTCOffset     int64      `json:"utc_offset"`
+	WaitlistCount int        `json:"waitlist_count"`
+	YesRSVPCount  int        `json:"yes_rsvp_count"`
+	Venue         Venue      `json:"venue"`
+	Group         EventGroup `json:"group"`
+	Link          string     `json:"link"`
+	Description   string     `json:"description"`
+	Visibility    string     `json:"visibility"`
+}
+
+// Venue is a location where events happen
+type Venue struct {
+	ID                   int     `json:"id"`
+	Name                 string  `json:"name"`
+	Lat                  float64 `json:"lat"`
+	Lon                  float64 `json:"lon"`
+	Repinned             bool    `json:"repinned"`
+	Address1             string  `json:"address_1"`
+	Address2             string  `json:"address_2"`
+	City                 string  `json:"city"`
+	Country              string  `json:"country"`
+	LocalizedCountryName string  `json:"localized_country_name"`
+	Zip                  string  `json:"zip"`
+	State                string  `json:"state"`
+}
+
+// Member is a meetup.com user
+type Member struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+// RSVP is a member's response to an event
+type RSVP struct {
+	Created  int64  `json:"created"`
+	Updated  int64  `json:"updated"`
+	Response string `json:"response"`
+	Guests   int    `json:"guests"`
+	Member   Member `json:"member"`
+}
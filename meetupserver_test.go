@@ -0,0 +1,219 @@
+package main
+
+import (
+	"crypto/sha1"
+	"encoding/json"
+	"fmt"
+	"github.com/jaredkotoff/meetup-bot/meetup"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testAPIKey is the only key the fake Meetup server accepts
+const testAPIKey = "test-key"
+
+// fixtureDir holds the fake Meetup server's responses
+var fixtureDir = filepath.Join("testdata", "meetup")
+
+// fakeMeetup stands in for api.meetup.com, serving groups and events from
+// the fixtures in testdata/meetup. Successful responses carry an ETag and
+// are answered with 304 Not Modified when revalidated. Tests can change a
+// group's events and have requests rate limited.
+type fakeMeetup struct {
+	*httptest.Server
+
+	mu sync.Mutex
+	// events holds each group's events, soonest first
+	events map[string][]meetup.Event
+	// throttled is how many of the next requests get a 429
+	throttled int
+	// requests counts every request served
+	requests int
+	// notModified counts the requests answered with 304 Not Modified
+	notModified int
+}
+
+// newFakeMeetup starts a fake Meetup server, closed when the test ends
+func newFakeMeetup(t *testing.T) *fakeMeetup {
+	fm := &fakeMeetup{events: make(map[string][]meetup.Event)}
+	files, err := filepath.Glob(filepath.Join(fixtureDir, "events", "*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range files {
+		var events []meetup.Event
+		readFixture(t, file, &events)
+		fm.events[strings.TrimSuffix(filepath.Base(file), ".json")] = events
+	}
+
+	fm.Server = httptest.NewServer(fm)
+	t.Cleanup(fm.Close)
+	return fm
+}
+
+// readFixture decodes a JSON fixture into target
+func readFixture(t *testing.T, path string, target interface{}) {
+	body, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(body, target); err != nil {
+		t.Fatalf("%v: %s", path, err)
+	}
+}
+
+// setupMeetup starts a fake Meetup server and points the test bot's Meetup
+// client at it
+func setupMeetup(t *testing.T, f *fakeSession) *fakeMeetup {
+	fm := newFakeMeetup(t)
+	cfg := f.bot.Config
+	cfg.APIKey = testAPIKey
+	cfg.MeetupURL = fm.URL
+	// Always fetch so tests see changes to events straight away
+	cfg.CacheTTL = "0s"
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	f.bot.Meetup = f.bot.newMeetupClient()
+	f.bot.Meetup.Throttle.BaseDelay = time.Millisecond
+	return fm
+}
+
+// setEvents replaces a group's events
+func (fm *fakeMeetup) setEvents(urlName string, events []meetup.Event) {
+	fm.mu.Lock()
+	defer fm.mu.Unlock()
+	fm.events[urlName] = events
+}
+
+// getEvents returns a copy of a group's events
+func (fm *fakeMeetup) getEvents(urlName string) []meetup.Event {
+	fm.mu.Lock()
+	defer fm.mu.Unlock()
+	return append([]meetup.Event(nil), fm.events[urlName]...)
+}
+
+// throttle answers the next n requests with 429 Too Many Requests
+func (fm *fakeMeetup) throttle(n int) {
+	fm.mu.Lock()
+	defer fm.mu.Unlock()
+	fm.throttled = n
+}
+
+// requestCount returns how many requests have been served
+func (fm *fakeMeetup) requestCount() int {
+	fm.mu.Lock()
+	defer fm.mu.Unlock()
+	return fm.requests
+}
+
+// notModifiedCount returns how many requests were answered with 304 Not
+// Modified
+func (fm *fakeMeetup) notModifiedCount() int {
+	fm.mu.Lock()
+	defer fm.mu.Unlock()
+	return fm.notModified
+}
+
+// ServeHTTP answers GET /:urlname and GET /:urlname/events like Meetup does
+func (fm *fakeMeetup) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	fm.mu.Lock()
+	defer fm.mu.Unlock()
+	fm.requests++
+
+	w.Header().Set("Content-Type", "application/json;charset=utf-8")
+	if fm.throttled > 0 {
+		fm.throttled--
+		w.Header().Set("X-RateLimit-Limit", "30")
+		w.Header().Set("X-RateLimit-Remaining", "0")
+		w.Header().Set("X-RateLimit-Reset", "0")
+		fm.serveFixture(w, r, http.StatusTooManyRequests, filepath.Join(fixtureDir, "errors", "throttled.json"))
+		return
+	}
+	w.Header().Set("X-RateLimit-Limit", "30")
+	w.Header().Set("X-RateLimit-Remaining", "30")
+	w.Header().Set("X-RateLimit-Reset", "10")
+
+	if r.URL.Query().Get("key") != testAPIKey {
+		fm.serveFixture(w, r, http.StatusUnauthorized, filepath.Join(fixtureDir, "errors", "unauthorized.json"))
+		return
+	}
+
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	group := filepath.Join(fixtureDir, "groups", parts[0]+".json")
+	if _, err := os.Stat(group); err != nil || r.Method != "GET" {
+		fm.serveFixture(w, r, http.StatusNotFound, filepath.Join(fixtureDir, "errors", "not_found.json"))
+		return
+	}
+
+	switch {
+	case len(parts) == 1:
+		fm.serveFixture(w, r, http.StatusOK, group)
+	case len(parts) == 2 && parts[1] == "events":
+		fm.serveEvents(w, r, parts[0])
+	default:
+		fm.serveFixture(w, r, http.StatusNotFound, filepath.Join(fixtureDir, "errors", "not_found.json"))
+	}
+}
+
+// serveEvents lists a group's events filtered by the status and page query
+// parameters. The caller must hold mu.
+func (fm *fakeMeetup) serveEvents(w http.ResponseWriter, r *http.Request, urlName string) {
+	statuses := []string{"upcoming"}
+	if status := r.URL.Query().Get("status"); status != "" {
+		statuses = strings.Split(status, ",")
+	}
+	page, err := strconv.Atoi(r.URL.Query().Get("page"))
+	if err != nil {
+		page = 200
+	}
+
+	events := []meetup.Event{}
+	for _, event := range fm.events[urlName] {
+		if containsString(statuses, event.Status) && len(events) < page {
+			events = append(events, event)
+		}
+	}
+	body, err := json.Marshal(events)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	fm.serveBody(w, r, http.StatusOK, body)
+}
+
+// serveFixture writes a fixture file as the response body. The caller must
+// hold mu.
+func (fm *fakeMeetup) serveFixture(w http.ResponseWriter, r *http.Request, status int, path string) {
+	body, err := ioutil.ReadFile(path)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	fm.serveBody(w, r, status, body)
+}
+
+// serveBody writes a response. Successful responses are tagged with a hash
+// of the body, and requests already holding that tag get a 304 with no body.
+// The caller must hold mu.
+func (fm *fakeMeetup) serveBody(w http.ResponseWriter, r *http.Request, status int, body []byte) {
+	if status == http.StatusOK {
+		etag := fmt.Sprintf(`"%x"`, sha1.Sum(body))
+		w.Header().Set("ETag", etag)
+		if r.Header.Get("If-None-Match") == etag {
+			fm.notModified++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+	}
+	w.WriteHeader(status)
+	w.Write(body)
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"github.com/jaredkotoff/meetup-bot/meetup"
+	"sync"
+)
+
+// memoryGuild is everything a memoryStore keeps for one guild
+type memoryGuild struct {
+	settings  map[string][]byte
+	events    map[string]meetup.Event
+	reminders map[string][]string
+	history   map[string][]byte
+}
+
+func newMemoryGuild() *memoryGuild {
+	return &memoryGuild{
+		settings:  make(map[string][]byte),
+		events:    make(map[string]meetup.Event),
+		reminders: make(map[string][]string),
+		history:   make(map[string][]byte),
+	}
+}
+
+// memoryStore keeps guild state in memory, for running without a db file.
+// Values are copied in and out so callers can't change what is stored.
+type memoryStore struct {
+	mu      sync.Mutex
+	guilds  map[string]*memoryGuild
+	archive map[string]*memoryGuild
+}
+
+// newMemoryStore returns an empty Store held in memory
+func newMemoryStore() *memoryStore {
+	return &memoryStore{
+		guilds:  make(map[string]*memoryGuild),
+		archive: make(map[string]*memoryGuild),
+	}
+}
+
+// guild returns a guild's state. The caller must hold mu.
+func (ms *memoryStore) guild(guildID string) (*memoryGuild, error) {
+	g, ok := ms.guilds[guildID]
+	if !ok {
+		return nil, noGuildError(guildID)
+	}
+	return g, nil
+}
+
+// Guilds returns the ID of every guild with settings
+func (ms *memoryStore) Guilds() ([]string, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	var ids []string
+	for id := range ms.guilds {
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// EnsureGuild creates a guild's settings, restoring archived ones
+func (ms *memoryStore) EnsureGuild(guildID string) (bool, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	if _, ok := ms.guilds[guildID]; ok {
+		return false, nil
+	}
+	if g, ok := ms.archive[guildID]; ok {
+		ms.guilds[guildID] = g
+		delete(ms.archive, guildID)
+		return true, nil
+	}
+	ms.guilds[guildID] = newMemoryGuild()
+	return false, nil
+}
+
+// ArchiveGuild moves a guild's settings into the archive
+func (ms *memoryStore) ArchiveGuild(guildID string) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	if g, ok := ms.guilds[guildID]; ok {
+		ms.archive[guildID] = g
+		delete(ms.guilds, guildID)
+	}
+	return nil
+}
+
+// PurgeGuild deletes a guild's settings and any archived copy
+func (ms *memoryStore) PurgeGuild(guildID string) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	delete(ms.guilds, guildID)
+	delete(ms.archive, guildID)
+	return nil
+}
+
+// Setting reads a single setting
+func (ms *memoryStore) Setting(guildID, key string) ([]byte, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	g, err := ms.guild(guildID)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(nil), g.settings[key]...), nil
+}
+
+// Settings returns every setting of a guild
+func (ms *memoryStore) Settings(guildID string) (map[string][]byte, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	g, err := ms.guild(guildID)
+	if err != nil {
+		return nil, err
+	}
+	settings := make(map[string][]byte)
+	for k, v := range g.settings {
+		settings[k] = append([]byte(nil), v...)
+	}
+	return settings, nil
+}
+
+// PutSetting writes a single setting
+func (ms *memoryStore) PutSetting(guildID, key string, value []byte) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	g, err := ms.guild(guildID)
+	if err != nil {
+		return err
+	}
+	g.settings[key] = append([]byte(nil), value...)
+	return nil
+}
+
+// DeleteSetting removes a single setting
+func (ms *memoryStore) DeleteSetting(guildID, key string) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	g, err := ms.guild(guildID)
+	if err != nil {
+		return err
+	}
+	delete(g.settings, key)
+	return nil
+}
+
+// TrackedEvents returns the events the guild tracks
+func (ms *memoryStore) TrackedEvents(guildID string) (map[string]meetup.Event, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	g, err := ms.guild(guildID)
+	if err != nil {
+		return nil, err
+	}
+	events := make(map[string]meetup.Event)
+	for id, event := range g.events {
+		events[id] = event
+	}
+	return events, nil
+}
+
+// PutTrackedEvents replaces the events the guild tracks
+func (ms *memoryStore) PutTrackedEvents(guildID string, events map[string]meetup.Event) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	g, err := ms.guild(guildID)
+	if err != nil {
+		return err
+	}
+	g.events = make(map[string]meetup.Event)
+	for id, event := range events {
+		g.events[id] = event
+	}
+	return nil
+}
+
+// SentReminders returns the reminder offsets already sent for each event
+func (ms *memoryStore) SentReminders(guildID string) (map[string][]string, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	g, err := ms.guild(guildID)
+	if err != nil {
+		return nil, err
+	}
+	sent := make(map[string][]string)
+	for id, offsets := range g.reminders {
+		sent[id] = append([]string(nil), offsets...)
+	}
+	return sent, nil
+}
+
+// PutSentReminders replaces the record of reminders sent
+func (ms *memoryStore) PutSentReminders(guildID string, sent map[string][]string) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	g, err := ms.guild(guildID)
+	if err != nil {
+		return err
+	}
+	g.reminders = make(map[string][]string)
+	for id, offsets := range sent {
+		g.reminders[id] = append([]string(nil), offsets...)
+	}
+	return nil
+}
+
+// PutHistory records value under key in the guild's history
+func (ms *memoryStore) PutHistory(guildID, key string, value []byte) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	g, err := ms.guild(guildID)
+	if err != nil {
+		return err
+	}
+	g.history[key] = append([]byte(nil), value...)
+	return nil
+}
@@ -0,0 +1,289 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// latencyBuckets are the upper bounds in seconds of the latency histograms
+var latencyBuckets = []float64{0.01, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+var (
+	commandsTotal = newCounter("meetupbot_commands_total",
+		"Commands run, by command and outcome.", "command", "outcome")
+	commandDuration = newHistogram("meetupbot_command_duration_seconds",
+		"How long command handlers take.", latencyBuckets, "command")
+	meetupRequestsTotal = newCounter("meetupbot_meetup_requests_total",
+		"Requests sent to the Meetup API, by endpoint and status code.", "endpoint", "code")
+	meetupRequestDuration = newHistogram("meetupbot_meetup_request_duration_seconds",
+		"How long Meetup API requests take.", latencyBuckets, "endpoint")
+	pollDuration = newHistogram("meetupbot_poll_duration_seconds",
+		"How long polling a guild's groups takes.", latencyBuckets)
+	announcementsTotal = newCounter("meetupbot_announcements_total",
+		"Announcements posted, by kind.", "kind")
+	remindersTotal = newCounter("meetupbot_reminders_total",
+		"Reminders posted.")
+)
+
+// metrics returns the metrics written to /metrics, in order
+func (b *Bot) metrics() []metric {
+	return []metric{
+		commandsTotal,
+		commandDuration,
+		meetupRequestsTotal,
+		meetupRequestDuration,
+		pollDuration,
+		announcementsTotal,
+		remindersTotal,
+		gaugeFunc{"meetupbot_guilds", "Servers the bot is in.", b.countGuilds},
+		gaugeFunc{"meetupbot_tracked_events", "Events tracked for announcements across servers.", b.countTrackedEvents},
+	}
+}
+
+// metric is anything that can write itself in the Prometheus text format
+type metric interface {
+	write(w io.Writer)
+}
+
+// counter is a Prometheus counter with a value for each set of label values
+type counter struct {
+	name, help string
+	labels     []string
+
+	mu     sync.Mutex
+	values map[string]float64
+}
+
+// newCounter creates a counter partitioned by labels
+func newCounter(name, help string, labels ...string) *counter {
+	return &counter{name: name, help: help, labels: labels, values: make(map[string]float64)}
+}
+
+// Inc adds one to the counter for the label values, given in the order the
+// labels were declared
+func (c *counter) Inc(values ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.values[labelString(c.labels, values)]++
+}
+
+// value returns the count for the label values
+func (c *counter) value(values ...string) float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.values[labelString(c.labels, values)]
+}
+
+func (c *counter) write(w io.Writer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	writeHeader(w, c.name, c.help, "counter")
+	if len(c.labels) == 0 {
+		fmt.Fprintf(w, "%v %v\n", c.name, formatValue(c.values[""]))
+		return
+	}
+	for _, labels := range sortedKeys(c.values) {
+		fmt.Fprintf(w, "%v{%v} %v\n", c.name, labels, formatValue(c.values[labels]))
+	}
+}
+
+// histogram is a Prometheus histogram with a series for each set of label
+// values
+type histogram struct {
+	name, help string
+	labels     []string
+	buckets    []float64
+
+	mu     sync.Mutex
+	series map[string]*histogramSeries
+}
+
+// histogramSeries holds one set of label values' observations
+type histogramSeries struct {
+	// counts holds the observations in each bucket, not cumulative, with
+	// one more for +Inf
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+// newHistogram creates a histogram with the bucket upper bounds, which must be
+// sorted, partitioned by labels
+func newHistogram(name, help string, buckets []float64, labels ...string) *histogram {
+	return &histogram{
+		name:    name,
+		help:    help,
+		labels:  labels,
+		buckets: buckets,
+		series:  make(map[string]*histogramSeries),
+	}
+}
+
+// Observe records a duration for the label values
+func (h *histogram) Observe(d time.Duration, values ...string) {
+	seconds := d.Seconds()
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	key := labelString(h.labels, values)
+	series, ok := h.series[key]
+	if !ok {
+		series = &histogramSeries{counts: make([]uint64, len(h.buckets)+1)}
+		h.series[key] = series
+	}
+	series.counts[sort.SearchFloat64s(h.buckets, seconds)]++
+	series.sum += seconds
+	series.count++
+}
+
+func (h *histogram) write(w io.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	writeHeader(w, h.name, h.help, "histogram")
+	for _, labels := range sortedKeys(h.series) {
+		series := h.series[labels]
+		sep := ""
+		if labels != "" {
+			sep = ","
+		}
+		var cumulative uint64
+		for i, count := range series.counts {
+			cumulative += count
+			le := "+Inf"
+			if i < len(h.buckets) {
+				le = formatValue(h.buckets[i])
+			}
+			fmt.Fprintf(w, "%v_bucket{%v%vle=\"%v\"} %v\n", h.name, labels, sep, le, cumulative)
+		}
+		fmt.Fprintf(w, "%v_sum%v %v\n", h.name, braces(labels), formatValue(series.sum))
+		fmt.Fprintf(w, "%v_count%v %v\n", h.name, braces(labels), series.count)
+	}
+}
+
+// gaugeFunc is a Prometheus gauge whose value is read when it is written
+type gaugeFunc struct {
+	name, help string
+	value      func() (float64, error)
+}
+
+func (g gaugeFunc) write(w io.Writer) {
+	value, err := g.value()
+	if err != nil {
+		log.Printf("Error reading %v: %s\n", g.name, err.Error())
+		return
+	}
+	writeHeader(w, g.name, g.help, "gauge")
+	fmt.Fprintf(w, "%v %v\n", g.name, formatValue(value))
+}
+
+// countGuilds returns how many guilds the bot has settings for
+func (b *Bot) countGuilds() (float64, error) {
+	guilds, err := b.Store.Guilds()
+	return float64(len(guilds)), err
+}
+
+// countTrackedEvents returns how many events are tracked across all guilds
+func (b *Bot) countTrackedEvents() (float64, error) {
+	guilds, err := b.Store.Guilds()
+	if err != nil {
+		return 0, err
+	}
+	total := 0
+	for _, guildID := range guilds {
+		tracked, err := b.Store.TrackedEvents(guildID)
+		if err != nil {
+			return 0, err
+		}
+		total += len(tracked)
+	}
+	return float64(total), nil
+}
+
+// recordMeetupRequest is the meetup client's OnRequest hook. It also counts
+// failed requests for StatHat, leaving out groups that weren't found, which
+// are urlnames mistyped in !setgroup and !addgroup rather than Meetup errors.
+func (b *Bot) recordMeetupRequest(endpoint string, status int, elapsed time.Duration) {
+	code := "error"
+	if status != 0 {
+		code = strconv.Itoa(status)
+	}
+	meetupRequestsTotal.Inc(endpoint, code)
+	unknownGroup := endpoint == "group" && status == http.StatusNotFound
+	if (status == 0 || status >= 400) && !unknownGroup {
+		b.Stats.Count(statMeetupErr)
+	}
+	meetupRequestDuration.Observe(elapsed, endpoint)
+}
+
+// labelString formats label names and values as they appear between braces,
+// e.g. `command="help",outcome="ok"`
+func labelString(names, values []string) string {
+	if len(names) != len(values) {
+		panic(fmt.Sprintf("got %v label values for %v labels", len(values), len(names)))
+	}
+	pairs := make([]string, len(names))
+	for i, name := range names {
+		pairs[i] = name + `="` + labelEscaper.Replace(values[i]) + `"`
+	}
+	return strings.Join(pairs, ",")
+}
+
+// labelEscaper escapes label values for the text format
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// braces wraps labels in braces unless there are none
+func braces(labels string) string {
+	if labels == "" {
+		return ""
+	}
+	return "{" + labels + "}"
+}
+
+// writeHeader writes a metric's HELP and TYPE lines
+func writeHeader(w io.Writer, name, help, kind string) {
+	fmt.Fprintf(w, "# HELP %v %v\n# TYPE %v %v\n", name, help, name, kind)
+}
+
+// formatValue formats a sample value the way Prometheus expects
+func formatValue(v float64) string {
+	if math.IsInf(v, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// sortedKeys returns the keys of a map of label strings in order, so output
+// is stable between scrapes
+func sortedKeys(m interface{}) []string {
+	var keys []string
+	switch m := m.(type) {
+	case map[string]float64:
+		for key := range m {
+			keys = append(keys, key)
+		}
+	case map[string]*histogramSeries:
+		for key := range m {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// serveMetrics writes every metric in the Prometheus text format
+func (b *Bot) serveMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	buf := bufio.NewWriter(w)
+	for _, m := range b.metrics() {
+		m.write(buf)
+	}
+	buf.Flush()
+}
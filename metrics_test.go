@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCounterFormat(t *testing.T) {
+	t.Parallel()
+	c := newCounter("test_total", "Test counter.", "name", "outcome")
+	c.Inc("b", "ok")
+	c.Inc("a", "ok")
+	c.Inc("a", "ok")
+	c.Inc(`quote"d`, "line\nbreak")
+
+	var buf bytes.Buffer
+	c.write(&buf)
+	want := `# HELP test_total Test counter.
+# TYPE test_total counter
+test_total{name="a",outcome="ok"} 2
+test_total{name="b",outcome="ok"} 1
+test_total{name="quote\"d",outcome="line\nbreak"} 1
+`
+	if buf.String() != want {
+		t.Errorf("got:\n%v\nwant:\n%v", buf.String(), want)
+	}
+
+	unlabeled := newCounter("plain_total", "Plain counter.")
+	buf.Reset()
+	unlabeled.write(&buf)
+	if want := "# HELP plain_total Plain counter.\n# TYPE plain_total counter\nplain_total 0\n"; buf.String() != want {
+		t.Errorf("got:\n%v\nwant:\n%v", buf.String(), want)
+	}
+}
+
+func TestHistogramFormat(t *testing.T) {
+	t.Parallel()
+	h := newHistogram("test_seconds", "Test histogram.", []float64{0.1, 1}, "name")
+	h.Observe(50*time.Millisecond, "a")
+	h.Observe(100*time.Millisecond, "a")
+	h.Observe(500*time.Millisecond, "a")
+	h.Observe(2*time.Second, "a")
+
+	var buf bytes.Buffer
+	h.write(&buf)
+	want := `# HELP test_seconds Test histogram.
+# TYPE test_seconds histogram
+test_seconds_bucket{name="a",le="0.1"} 2
+test_seconds_bucket{name="a",le="1"} 3
+test_seconds_bucket{name="a",le="+Inf"} 4
+test_seconds_sum{name="a"} 2.65
+test_seconds_count{name="a"} 4
+`
+	if buf.String() != want {
+		t.Errorf("got:\n%v\nwant:\n%v", buf.String(), want)
+	}
+}
+
+// The tests below compare process-wide counters before and after, so they
+// don't run in parallel with the tests that change them
+
+func TestCommandMetrics(t *testing.T) {
+	f := setupTest(t)
+	tests := []struct {
+		author, content, command, outcome string
+	}{
+		{testUserID, "!help", "help", "ok"},
+		{testUserID, "!setgroup golang-chicago", "setgroup", "denied"},
+		{testAdminID, "!setgroup", "setgroup", "usage"},
+		{testAdminID, "!adminrole add Nobody", "adminrole", "error"},
+	}
+	for _, test := range tests {
+		before := commandsTotal.value(test.command, test.outcome)
+		f.run(test.author, testChannelID, test.content)
+		if got := commandsTotal.value(test.command, test.outcome) - before; got != 1 {
+			t.Errorf("%v counted %v times as %v, want once", test.content, got, test.outcome)
+		}
+	}
+}
+
+func TestMeetupRequestMetrics(t *testing.T) {
+	f := setupTest(t)
+	fm := setupMeetup(t, f)
+	f.putSetting(t, groupsKey, `["golang-chicago"]`)
+
+	ok := meetupRequestsTotal.value("events", "200")
+	throttled := meetupRequestsTotal.value("events", "429")
+	fm.throttle(1)
+	f.run(testUserID, testChannelID, "!next")
+	if got := meetupRequestsTotal.value("events", "429") - throttled; got != 1 {
+		t.Errorf("counted %v throttled requests, want 1", got)
+	}
+	if got := meetupRequestsTotal.value("events", "200") - ok; got != 1 {
+		t.Errorf("counted %v successful requests, want 1", got)
+	}
+}
+
+func TestAnnouncementMetrics(t *testing.T) {
+	f := setupTest(t)
+	fm := setupMeetup(t, f)
+	f.putSetting(t, groupsKey, `["golang-chicago"]`)
+	f.putSetting(t, announceChannelKey, "announcements")
+	f.putSetting(t, remindersKey, `["1d"]`)
+	if err := f.bot.pollGuild(context.Background(), f, testGuildID); err != nil {
+		t.Fatal(err)
+	}
+
+	announced := announcementsTotal.value("new")
+	events := fm.getEvents("golang-chicago")
+	added := events[0]
+	added.ID = "250000006"
+	fm.setEvents("golang-chicago", append(events, added))
+	if err := f.bot.pollGuild(context.Background(), f, testGuildID); err != nil {
+		t.Fatal(err)
+	}
+	if got := announcementsTotal.value("new") - announced; got != 1 {
+		t.Errorf("counted %v announcements, want 1", got)
+	}
+
+	reminded := remindersTotal.value()
+	if err := f.bot.sendReminders(f, testGuildID, msToTime(added.Time).Add(-time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	// Both copies of the event start at the same time
+	if got := remindersTotal.value() - reminded; got != 2 {
+		t.Errorf("counted %v reminders, want 2", got)
+	}
+}
+
+func TestMetricsEndpoint(t *testing.T) {
+	t.Parallel()
+	f := setupTest(t)
+	setupMeetup(t, f)
+	f.putSetting(t, groupsKey, `["golang-chicago"]`)
+	f.putSetting(t, announceChannelKey, "announcements")
+	if err := f.bot.pollGuild(context.Background(), f, testGuildID); err != nil {
+		t.Fatal(err)
+	}
+
+	rec := getStatus(t, f, "/metrics")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("got %v: %v", rec.Code, rec.Body)
+	}
+	body := rec.Body.String()
+	for _, want := range []string{
+		"# TYPE meetupbot_guilds gauge\nmeetupbot_guilds 1\n",
+		"# TYPE meetupbot_tracked_events gauge\nmeetupbot_tracked_events 5\n",
+		"# TYPE meetupbot_commands_total counter\n",
+		"# TYPE meetupbot_meetup_request_duration_seconds histogram\n",
+		`meetupbot_meetup_requests_total{endpoint="events",code="200"}`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("/metrics is missing %q:\n%v", want, body)
+		}
+	}
+}
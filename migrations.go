@@ -0,0 +1,245 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/boltdb/bolt"
+	"log"
+	"strconv"
+	"time"
+)
+
+const (
+	// metaBucket is the top-level bucket holding facts about the db itself
+	metaBucket = "meta"
+	// schemaVersionKey holds the version of the layout the db is in
+	schemaVersionKey = "version"
+)
+
+// Keys that guild buckets used before settings were split into nested buckets
+const (
+	legacyURLNameKey       = "urlname"
+	legacyEventsKey        = "events"
+	legacySentRemindersKey = "sentreminders"
+	legacyNextEventKey     = "nextevent"
+)
+
+// migration upgrades the db to version. Each runs in its own transaction
+// along with the version bump, so a failed migration leaves the db as it was.
+type migration struct {
+	version     int
+	description string
+	apply       func(tx *bolt.Tx) error
+}
+
+// migrations upgrade older dbs to the current layout, oldest first
+var migrations = []migration{
+	{1, "move guild settings into nested buckets", migrateNestedBuckets},
+}
+
+// currentSchemaVersion is the layout this build reads and writes
+func currentSchemaVersion() int {
+	return migrations[len(migrations)-1].version
+}
+
+// migrate upgrades the db at path to the current layout, backing it up first
+// if it holds anything
+func migrate(db *bolt.DB, path string) error {
+	version, empty := 0, true
+	err := db.View(func(tx *bolt.Tx) error {
+		var err error
+		version, err = schemaVersion(tx)
+		k, _ := tx.Cursor().First()
+		empty = k == nil
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if version > currentSchemaVersion() {
+		return fmt.Errorf("%v is schema version %v but this build only supports up to %v",
+			path, version, currentSchemaVersion())
+	}
+	if version == currentSchemaVersion() {
+		return nil
+	}
+
+	if !empty {
+		backup := fmt.Sprintf("%v.bak-v%v-%v", path, version, time.Now().Format("20060102150405"))
+		err := db.View(func(tx *bolt.Tx) error {
+			return tx.CopyFile(backup, 0600)
+		})
+		if err != nil {
+			return fmt.Errorf("backing up %v: %s", path, err)
+		}
+		log.Printf("Backed up %v to %v\n", path, backup)
+	}
+
+	for _, m := range migrations {
+		if m.version <= version {
+			continue
+		}
+		err := db.Update(func(tx *bolt.Tx) error {
+			if err := m.apply(tx); err != nil {
+				return err
+			}
+			return setSchemaVersion(tx, m.version)
+		})
+		if err != nil {
+			return fmt.Errorf("migrating to version %v (%v): %s", m.version, m.description, err)
+		}
+		log.Printf("Migrated %v to version %v: %v\n", path, m.version, m.description)
+	}
+	return nil
+}
+
+// schemaVersion reads the db's layout version, 0 for dbs from before it was
+// recorded
+func schemaVersion(tx *bolt.Tx) (int, error) {
+	meta := tx.Bucket([]byte(metaBucket))
+	if meta == nil {
+		return 0, nil
+	}
+	v := meta.Get([]byte(schemaVersionKey))
+	if v == nil {
+		return 0, nil
+	}
+	return strconv.Atoi(string(v))
+}
+
+// setSchemaVersion records the db's layout version
+func setSchemaVersion(tx *bolt.Tx, version int) error {
+	meta, err := tx.CreateBucketIfNotExists([]byte(metaBucket))
+	if err != nil {
+		return err
+	}
+	return meta.Put([]byte(schemaVersionKey), []byte(strconv.Itoa(version)))
+}
+
+// migrateNestedBuckets moves each guild's flat top-level bucket into the
+// guilds bucket, splitting its keys into settings, tracked events, sent
+// reminders and history. Archived guilds are split the same way.
+func migrateNestedBuckets(tx *bolt.Tx) error {
+	var legacy []string
+	err := tx.ForEach(func(name []byte, _ *bolt.Bucket) error {
+		switch string(name) {
+		case metaBucket, guildsBucket, archiveBucket, cacheBucket:
+		default:
+			legacy = append(legacy, string(name))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	guilds, err := tx.CreateBucketIfNotExists([]byte(guildsBucket))
+	if err != nil {
+		return err
+	}
+	for _, guildID := range legacy {
+		values, err := bucketValues(tx.Bucket([]byte(guildID)))
+		if err != nil {
+			return err
+		}
+		if err := tx.DeleteBucket([]byte(guildID)); err != nil {
+			return err
+		}
+		if err := splitLegacyGuild(guilds, guildID, values); err != nil {
+			return err
+		}
+	}
+
+	archive := tx.Bucket([]byte(archiveBucket))
+	if archive == nil {
+		return nil
+	}
+	var archived []string
+	err = archive.ForEach(func(k, v []byte) error {
+		if v == nil {
+			archived = append(archived, string(k))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, guildID := range archived {
+		values, err := bucketValues(archive.Bucket([]byte(guildID)))
+		if err != nil {
+			return err
+		}
+		if err := archive.DeleteBucket([]byte(guildID)); err != nil {
+			return err
+		}
+		if err := splitLegacyGuild(archive, guildID, values); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// bucketValues copies the keys of a bucket, skipping nested buckets, so they
+// outlive changes to it
+func bucketValues(b *bolt.Bucket) (map[string][]byte, error) {
+	values := make(map[string][]byte)
+	err := b.ForEach(func(k, v []byte) error {
+		if v != nil {
+			values[string(k)] = append([]byte(nil), v...)
+		}
+		return nil
+	})
+	return values, err
+}
+
+// splitLegacyGuild writes a flat guild bucket's values into a nested guild
+// bucket under parent
+func splitLegacyGuild(parent *bolt.Bucket, guildID string, values map[string][]byte) error {
+	guild, err := createGuildBucket(parent, guildID)
+	if err != nil {
+		return err
+	}
+	settings := guild.Bucket([]byte(settingsBucket))
+
+	for key, v := range values {
+		switch key {
+		case legacyEventsKey:
+			err = splitLegacyMap(guild.Bucket([]byte(eventsBucket)), v)
+		case legacySentRemindersKey:
+			err = splitLegacyMap(guild.Bucket([]byte(remindersBucket)), v)
+		case legacyNextEventKey:
+			err = guild.Bucket([]byte(historyBucket)).Put([]byte(nextEventKey), v)
+		case legacyURLNameKey:
+			// The single group guilds followed before multiple groups were
+			// supported becomes the groups list, unless one already exists
+			if _, ok := values[groupsKey]; ok || len(v) == 0 {
+				continue
+			}
+			var groups []byte
+			groups, err = json.Marshal([]string{string(v)})
+			if err == nil {
+				err = settings.Put([]byte(groupsKey), groups)
+			}
+		default:
+			err = settings.Put([]byte(key), v)
+		}
+		if err != nil {
+			return fmt.Errorf("guild %v key %v: %s", guildID, key, err)
+		}
+	}
+	return nil
+}
+
+// splitLegacyMap stores each entry of a JSON object as its own key in b
+func splitLegacyMap(b *bolt.Bucket, v []byte) error {
+	entries := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(v, &entries); err != nil {
+		return err
+	}
+	for k, entry := range entries {
+		if err := b.Put([]byte(k), entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"github.com/boltdb/bolt"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+// putLegacyGuild writes a guild bucket laid out as before schema version 1,
+// with every value as a flat key
+func putLegacyGuild(parent interface {
+	CreateBucket([]byte) (*bolt.Bucket, error)
+}, guildID string, values map[string]string) error {
+	b, err := parent.CreateBucket([]byte(guildID))
+	if err != nil {
+		return err
+	}
+	for k, v := range values {
+		if err := b.Put([]byte(k), []byte(v)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setupLegacyDB fills a db the way a version 0 bot left it
+func setupLegacyDB(tx *bolt.Tx) error {
+	err := putLegacyGuild(tx, "one", map[string]string{
+		legacyURLNameKey:       "golang-chicago",
+		legacyEventsKey:        `{"1":{"id":"1","name":"One"},"2":{"id":"2","name":"Two"}}`,
+		legacySentRemindersKey: `{"1":["24h0m0s"]}`,
+		legacyNextEventKey:     `{"id":"1"}`,
+		"prefix":               ".",
+	})
+	if err != nil {
+		return err
+	}
+	// A groups list already set wins over the old single group
+	err = putLegacyGuild(tx, "two", map[string]string{
+		legacyURLNameKey: "golang-chicago",
+		groupsKey:        `["chicago-rust","golang-chicago"]`,
+	})
+	if err != nil {
+		return err
+	}
+
+	archive, err := tx.CreateBucket([]byte(archiveBucket))
+	if err != nil {
+		return err
+	}
+	err = putLegacyGuild(archive, "left", map[string]string{
+		legacyURLNameKey: "chicago-rust",
+		legacyEventsKey:  `{"3":{"id":"3","name":"Three"}}`,
+	})
+	if err != nil {
+		return err
+	}
+
+	cache, err := tx.CreateBucket([]byte(cacheBucket))
+	if err != nil {
+		return err
+	}
+	return cache.Put([]byte("group?"), []byte(`{"body":"e30="}`))
+}
+
+// dumpDB flattens a db into slash separated paths, with buckets ending in a
+// slash and holding no value
+func dumpDB(t *testing.T, db *bolt.DB) map[string]string {
+	dump := make(map[string]string)
+	var walk func(prefix string, b *bolt.Bucket) error
+	walk = func(prefix string, b *bolt.Bucket) error {
+		return b.ForEach(func(k, v []byte) error {
+			if v != nil {
+				dump[prefix+string(k)] = string(v)
+				return nil
+			}
+			dump[prefix+string(k)+"/"] = ""
+			return walk(prefix+string(k)+"/", b.Bucket(k))
+		})
+	}
+	err := db.View(func(tx *bolt.Tx) error {
+		return tx.ForEach(func(name []byte, b *bolt.Bucket) error {
+			dump[string(name)+"/"] = ""
+			return walk(string(name)+"/", b)
+		})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dump
+}
+
+// backups lists the backups written next to a db
+func backups(t *testing.T, db *bolt.DB) []string {
+	matches, err := filepath.Glob(db.Path() + ".bak-v*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return matches
+}
+
+func TestMigrateNestedBuckets(t *testing.T) {
+	t.Parallel()
+	s := openTestDB(t, setupLegacyDB)
+
+	want := map[string]string{
+		"guilds/":                      "",
+		"guilds/one/":                  "",
+		"guilds/one/settings/":         "",
+		"guilds/one/settings/groups":   `["golang-chicago"]`,
+		"guilds/one/settings/prefix":   ".",
+		"guilds/one/events/":           "",
+		"guilds/one/events/1":          `{"id":"1","name":"One"}`,
+		"guilds/one/events/2":          `{"id":"2","name":"Two"}`,
+		"guilds/one/reminders/":        "",
+		"guilds/one/reminders/1":       `["24h0m0s"]`,
+		"guilds/one/history/":          "",
+		"guilds/one/history/nextevent": `{"id":"1"}`,
+		"guilds/two/":                  "",
+		"guilds/two/settings/":         "",
+		"guilds/two/settings/groups":   `["chicago-rust","golang-chicago"]`,
+		"guilds/two/events/":           "",
+		"guilds/two/reminders/":        "",
+		"guilds/two/history/":          "",
+		"archive/":                     "",
+		"archive/left/":                "",
+		"archive/left/settings/":       "",
+		"archive/left/settings/groups": `["chicago-rust"]`,
+		"archive/left/events/":         "",
+		"archive/left/events/3":        `{"id":"3","name":"Three"}`,
+		"archive/left/reminders/":      "",
+		"archive/left/history/":        "",
+		"meetupcache/":                 "",
+		"meetupcache/group?":           `{"body":"e30="}`,
+		"meta/":                        "",
+		"meta/version":                 strconv.Itoa(currentSchemaVersion()),
+	}
+	migrated := dumpDB(t, s.db)
+	if !reflect.DeepEqual(migrated, want) {
+		t.Errorf("migrated db:\n%v\nwant:\n%v", migrated, want)
+	}
+
+	// The store reads the new layout, and the archived guild can come back
+	if groups, err := (&Bot{Store: s}).getGroups("one"); err != nil || !reflect.DeepEqual(groups, []string{"golang-chicago"}) {
+		t.Errorf("groups = %v, %v", groups, err)
+	}
+	if restored, err := s.EnsureGuild("left"); err != nil || !restored {
+		t.Errorf("EnsureGuild(left) = %v, %v, want restored", restored, err)
+	}
+
+	if n := len(backups(t, s.db)); n != 1 {
+		t.Fatalf("wrote %v backups, want 1", n)
+	}
+	backup, err := bolt.Open(backups(t, s.db)[0], 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer backup.Close()
+	if _, ok := dumpDB(t, backup)["one/urlname"]; !ok {
+		t.Error("backup doesn't hold the old layout")
+	}
+}
+
+func TestMigrateTwice(t *testing.T) {
+	t.Parallel()
+	s := openTestDB(t, setupLegacyDB)
+	before := dumpDB(t, s.db)
+	if err := migrate(s.db, s.db.Path()); err != nil {
+		t.Fatal(err)
+	}
+	if after := dumpDB(t, s.db); !reflect.DeepEqual(after, before) {
+		t.Errorf("second migration changed the db:\n%v\nwant:\n%v", after, before)
+	}
+	if n := len(backups(t, s.db)); n != 1 {
+		t.Errorf("wrote %v backups, want 1", n)
+	}
+}
+
+func TestMigrateEmpty(t *testing.T) {
+	t.Parallel()
+	s := openTestDB(t, nil)
+	want := map[string]string{
+		"guilds/":      "",
+		"meta/":        "",
+		"meta/version": strconv.Itoa(currentSchemaVersion()),
+	}
+	if got := dumpDB(t, s.db); !reflect.DeepEqual(got, want) {
+		t.Errorf("new db:\n%v\nwant:\n%v", got, want)
+	}
+	if n := len(backups(t, s.db)); n != 0 {
+		t.Errorf("backed up an empty db %v times", n)
+	}
+}
+
+func TestMigrateNewerVersion(t *testing.T) {
+	t.Parallel()
+	s := openTestDB(t, nil)
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		return setSchemaVersion(tx, currentSchemaVersion()+1)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := migrate(s.db, s.db.Path()); err == nil {
+		t.Error("migrated a db from a newer build")
+	}
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"fmt"
+	"github.com/bwmarrin/discordgo"
+	"strings"
+)
+
+// adminRolesKey is the bolt key holding a guild's allow-listed role IDs
+const adminRolesKey = "adminroles"
+
+// permissionNames maps Discord permission bits to the names shown in the client
+var permissionNames = map[int]string{
+	discordgo.PermissionKickMembers:     "Kick Members",
+	discordgo.PermissionBanMembers:      "Ban Members",
+	discordgo.PermissionManageRoles:     "Manage Roles",
+	discordgo.PermissionManageChannels:  "Manage Channels",
+	discordgo.PermissionManageServer:    "Manage Server",
+	discordgo.PermissionManageMessages:  "Manage Messages",
+	discordgo.PermissionMentionEveryone: "Mention Everyone",
+}
+
+func init() {
+	router.Register(&Command{
+		Name:        "adminrole",
+		Description: "Lets members of a role run admin commands",
+		Usage:       "<add|remove|list> [role]",
+		Args:        []Arg{{Name: "action"}, {Name: "role", Optional: true, Rest: true}},
+		Permission:  discordgo.PermissionManageServer,
+		Examples:    []string{"list", "add Organizers", "remove Organizers"},
+		Handler:     adminRole,
+	})
+}
+
+// permissionString describes the permission bits in perm for denial messages
+func permissionString(perm int) string {
+	var names []string
+	for bit := uint(0); bit < 32; bit++ {
+		if perm&(1<<bit) == 0 {
+			continue
+		}
+		if name, ok := permissionNames[1<<bit]; ok {
+			names = append(names, name)
+		} else {
+			names = append(names, fmt.Sprintf("0x%x", 1<<bit))
+		}
+	}
+	return strings.Join(names, ", ")
+}
+
+// canRun reports whether the author of m may run cmd. Users pass if they hold
+// every permission bit the command requires, or if they have one of the
+// guild's allow-listed roles.
+func (b *Bot) canRun(s Session, m *discordgo.MessageCreate, cmd *Command) (bool, error) {
+	if cmd.Permission == 0 {
+		return true, nil
+	}
+	access, err := b.authorAccess(s, m)
+	if err != nil {
+		return false, err
+	}
+	return access.allows(cmd), nil
+}
+
+// access is what the author of a message holds in the channel it was sent
+// in, looked up once so it can be checked against many commands
+type access struct {
+	// private is set for direct messages, where admin commands can't run
+	private bool
+	perms   int
+	// allowedRole is set if the author has one of the guild's allow-listed
+	// roles
+	allowedRole bool
+}
+
+// authorAccess looks up the channel, permissions, allow-list and roles that
+// decide which commands the author of m may run
+func (b *Bot) authorAccess(s Session, m *discordgo.MessageCreate) (*access, error) {
+	channel, err := getChannel(s, m.ChannelID)
+	if err != nil {
+		return nil, err
+	}
+	if channel.IsPrivate {
+		return &access{private: true}, nil
+	}
+
+	perms, err := s.UserChannelPermissions(m.Author.ID, m.ChannelID)
+	if err != nil {
+		return nil, err
+	}
+	a := &access{perms: perms}
+
+	var allowed []string
+	if err := b.getGuildJSON(channel.GuildID, adminRolesKey, &allowed); err != nil {
+		return nil, err
+	}
+	if len(allowed) == 0 {
+		return a, nil
+	}
+	member, err := s.GuildMember(channel.GuildID, m.Author.ID)
+	if err != nil {
+		return nil, err
+	}
+	for _, roleID := range member.Roles {
+		if containsString(allowed, roleID) {
+			a.allowedRole = true
+		}
+	}
+	return a, nil
+}
+
+// allows reports whether cmd may be run with this access
+func (a *access) allows(cmd *Command) bool {
+	switch {
+	case cmd.Permission == 0:
+		return true
+	case a.private:
+		// Admin commands only make sense inside a guild
+		return false
+	}
+	return a.perms&cmd.Permission == cmd.Permission || a.allowedRole
+}
+
+// memberPermissions works out what userID may do in channel. Unlike
+// UserChannelPermissions it starts from the guild's @everyone role, whose ID
+// is the guild's, and applies the channel's @everyone overwrite, so members
+// granted everything through @everyone aren't left with nothing.
+func memberPermissions(s Session, guildID, userID string, channel *discordgo.Channel) (int, error) {
+	member, err := s.GuildMember(guildID, userID)
+	if err != nil {
+		return 0, err
+	}
+	roles, err := s.GuildRoles(guildID)
+	if err != nil {
+		return 0, err
+	}
+	var perms int
+	for _, role := range roles {
+		if role.ID == guildID || containsString(member.Roles, role.ID) {
+			perms |= role.Permissions
+		}
+	}
+	// discordgo calls the Administrator bit PermissionManageRoles, and it
+	// overrides every overwrite
+	if perms&discordgo.PermissionManageRoles != 0 {
+		return discordgo.PermissionAll, nil
+	}
+
+	// Overwrites apply @everyone first, then the member's roles together,
+	// then the member
+	var roleDeny, roleAllow int
+	for _, overwrite := range channel.PermissionOverwrites {
+		if overwrite.Type == "role" && overwrite.ID == guildID {
+			perms = perms&^overwrite.Deny | overwrite.Allow
+		}
+	}
+	for _, overwrite := range channel.PermissionOverwrites {
+		if overwrite.Type == "role" && overwrite.ID != guildID && containsString(member.Roles, overwrite.ID) {
+			roleDeny |= overwrite.Deny
+			roleAllow |= overwrite.Allow
+		}
+	}
+	perms = perms&^roleDeny | roleAllow
+	for _, overwrite := range channel.PermissionOverwrites {
+		if overwrite.Type == "member" && overwrite.ID == userID {
+			perms = perms&^overwrite.Deny | overwrite.Allow
+		}
+	}
+	return perms, nil
+}
+
+// findRole resolves a role mention, ID or name to one of the guild's roles
+func findRole(s Session, guildID, query string) (*discordgo.Role, error) {
+	roles, err := s.GuildRoles(guildID)
+	if err != nil {
+		return nil, err
+	}
+	id := strings.TrimSuffix(strings.TrimPrefix(query, "<@&"), ">")
+	for _, role := range roles {
+		if role.ID == id || strings.EqualFold(role.Name, query) {
+			return role, nil
+		}
+	}
+	return nil, fmt.Errorf("No role found matching %v", query)
+}
+
+// Manages the list of roles allowed to run admin commands
+func adminRole(ctx *Context) error {
+	channel, err := getChannel(ctx.Session, ctx.Message.ChannelID)
+	if err != nil {
+		return err
+	}
+
+	var allowed []string
+	if err := ctx.Bot.getGuildJSON(channel.GuildID, adminRolesKey, &allowed); err != nil {
+		return err
+	}
+
+	action := strings.ToLower(ctx.Arg("action"))
+	if action == "list" {
+		if len(allowed) == 0 {
+			ctx.Reply("No roles are allowed to run admin commands")
+			return nil
+		}
+		roles, err := ctx.Session.GuildRoles(channel.GuildID)
+		if err != nil {
+			return err
+		}
+		var names []string
+		for _, role := range roles {
+			if containsString(allowed, role.ID) {
+				names = append(names, "`"+role.Name+"`")
+			}
+		}
+		ctx.Reply("Roles allowed to run admin commands: " + strings.Join(names, ", "))
+		return nil
+	}
+
+	if action != "add" && action != "remove" || ctx.Arg("role") == "" {
+		ctx.Usage()
+		return nil
+	}
+
+	role, err := findRole(ctx.Session, channel.GuildID, ctx.Arg("role"))
+	if err != nil {
+		return err
+	}
+
+	if action == "add" {
+		if !containsString(allowed, role.ID) {
+			allowed = append(allowed, role.ID)
+		}
+	} else {
+		allowed = removeString(allowed, role.ID)
+	}
+	if err := ctx.Bot.putGuildJSON(channel.GuildID, adminRolesKey, allowed); err != nil {
+		return err
+	}
+
+	if action == "add" {
+		ctx.Reply(fmt.Sprintf("Members of `%v` can now run admin commands", role.Name))
+	} else {
+		ctx.Reply(fmt.Sprintf("Members of `%v` can no longer run admin commands", role.Name))
+	}
+	return nil
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"github.com/bwmarrin/discordgo"
+	"testing"
+)
+
+func TestAdminRole(t *testing.T) {
+	t.Parallel()
+	runCommandTests(t, []commandTest{
+		{
+			name:     "list with no roles",
+			commands: []string{"!adminrole list"},
+			want:     []sentMessage{reply("No roles are allowed to run admin commands")},
+		},
+		{
+			name:     "add by name",
+			commands: []string{"!adminrole add organizers"},
+			want:     []sentMessage{reply("Members of `Organizers` can now run admin commands")},
+		},
+		{
+			name:     "add by mention",
+			commands: []string{"!adminrole add <@&organizers>"},
+			want:     []sentMessage{reply("Members of `Organizers` can now run admin commands")},
+		},
+		{
+			name:     "list",
+			commands: []string{"!adminrole add Organizers", "!adminrole add Members", "!adminrole list"},
+			want:     []sentMessage{reply("Roles allowed to run admin commands: `Organizers`, `Members`")},
+		},
+		{
+			name:     "remove",
+			commands: []string{"!adminrole add Organizers", "!adminrole remove Organizers", "!adminrole list"},
+			want:     []sentMessage{reply("No roles are allowed to run admin commands")},
+		},
+		{
+			name:     "unknown role",
+			commands: []string{"!adminrole add Nobody"},
+			want:     []sentMessage{reply("No role found matching Nobody")},
+		},
+		{
+			name:     "unknown action",
+			commands: []string{"!adminrole rename Organizers"},
+			want:     []sentMessage{reply("Usage: `!adminrole <add|remove|list> [role]`")},
+		},
+		{
+			name:     "add without a role",
+			commands: []string{"!adminrole add"},
+			want:     []sentMessage{reply("Usage: `!adminrole <add|remove|list> [role]`")},
+		},
+		{
+			name:     "denied without permission",
+			author:   testOrganizerID,
+			commands: []string{"!adminrole list"},
+			want:     denied("adminrole"),
+		},
+		{
+			name: "allowed by role",
+			setup: func(t *testing.T, f *fakeSession) {
+				f.putSetting(t, adminRolesKey, `["organizers"]`)
+			},
+			author:   testOrganizerID,
+			commands: []string{"!adminrole list"},
+			want:     []sentMessage{reply("Roles allowed to run admin commands: `Organizers`")},
+		},
+		{
+			name: "other roles still denied",
+			setup: func(t *testing.T, f *fakeSession) {
+				f.putSetting(t, adminRolesKey, `["organizers"]`)
+			},
+			author:   testUserID,
+			commands: []string{"!adminrole list"},
+			want:     denied("adminrole"),
+		},
+		{
+			name:     "denied in private channels",
+			channel:  "dm",
+			commands: []string{"!adminrole list"},
+			want:     []sentMessage{{"dm", "You need the Manage Server permission or an allowed role to run `!adminrole`"}},
+		},
+	})
+}
+
+func TestMemberPermissions(t *testing.T) {
+	t.Parallel()
+	const (
+		read  = discordgo.PermissionReadMessages
+		send  = discordgo.PermissionSendMessages
+		admin = discordgo.PermissionManageRoles
+	)
+	overwrite := func(id, kind string, allow, deny int) *discordgo.PermissionOverwrite {
+		return &discordgo.PermissionOverwrite{ID: id, Type: kind, Allow: allow, Deny: deny}
+	}
+	tests := []struct {
+		name       string
+		everyone   int
+		roles      []string
+		overwrites []*discordgo.PermissionOverwrite
+		want       int
+	}{
+		{name: "@everyone only", everyone: read | send, want: read | send},
+		{name: "roles add to @everyone", everyone: read, roles: []string{"members"}, want: read | send},
+		{
+			name:       "@everyone overwrite",
+			everyone:   read | send,
+			overwrites: []*discordgo.PermissionOverwrite{overwrite(testGuildID, "role", 0, send)},
+			want:       read,
+		},
+		{
+			name:     "role overwrite beats @everyone's",
+			everyone: read | send,
+			roles:    []string{"organizers"},
+			overwrites: []*discordgo.PermissionOverwrite{
+				overwrite("organizers", "role", send, 0),
+				overwrite(testGuildID, "role", 0, send),
+			},
+			want: read | send,
+		},
+		{
+			name:     "member overwrite beats roles'",
+			everyone: read | send,
+			roles:    []string{"organizers"},
+			overwrites: []*discordgo.PermissionOverwrite{
+				overwrite(testBotID, "member", 0, send),
+				overwrite("organizers", "role", send, 0),
+			},
+			want: read,
+		},
+		{
+			name:       "other roles' overwrites ignored",
+			everyone:   read | send,
+			overwrites: []*discordgo.PermissionOverwrite{overwrite("members", "role", 0, send)},
+			want:       read | send,
+		},
+		{
+			name:       "administrator ignores overwrites",
+			everyone:   admin,
+			overwrites: []*discordgo.PermissionOverwrite{overwrite(testGuildID, "role", 0, send)},
+			want:       discordgo.PermissionAll,
+		},
+	}
+	for _, test := range tests {
+		f := newFakeSession()
+		f.Roles = []*discordgo.Role{
+			{ID: testGuildID, Permissions: test.everyone},
+			{ID: "members", Permissions: send},
+			{ID: "organizers"},
+		}
+		f.Members[testBotID].Roles = test.roles
+		channel := &discordgo.Channel{ID: testChannelID, PermissionOverwrites: test.overwrites}
+		perms, err := memberPermissions(f, testGuildID, testBotID, channel)
+		if err != nil || perms != test.want {
+			t.Errorf("%v: memberPermissions() = %#x, %v, want %#x", test.name, perms, err, test.want)
+		}
+	}
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"fmt"
+	"github.com/bwmarrin/discordgo"
+	"log"
+	"strings"
+	"sync"
+)
+
+const (
+	// prefixKey holds the prefix a guild's commands start with, overriding
+	// the default from the config
+	prefixKey = "prefix"
+	// maxPrefixLength is the longest prefix a guild can set
+	maxPrefixLength = 5
+)
+
+func init() {
+	router.Register(&Command{
+		Name:        "prefix",
+		Description: "Sets what commands start with on this server",
+		Usage:       "[prefix|reset]",
+		Args:        []Arg{{Name: "prefix", Optional: true}},
+		Permission:  discordgo.PermissionManageServer,
+		Examples:    []string{"", "?", "reset"},
+		Handler:     setPrefix,
+	})
+}
+
+// guildPrefix returns the prefix commands sent in channelID start with: the
+// guild's own if it has set one, otherwise fallback
+func (b *Bot) guildPrefix(s Session, channelID, fallback string) string {
+	channel, err := getChannel(s, channelID)
+	if err != nil || channel.IsPrivate {
+		return fallback
+	}
+	if prefix := b.Prefixes.get(b.Store, channel.GuildID); prefix != "" {
+		return prefix
+	}
+	return fallback
+}
+
+// mightBeCommand reports whether content starts with a mention of the bot,
+// fallback or any guild's prefix. Anything else can be ignored without
+// looking up which guild it was sent in.
+func (b *Bot) mightBeCommand(content, fallback string) bool {
+	return b.botMention(content) != "" || strings.HasPrefix(content, fallback) ||
+		b.Prefixes.matchAny(b.Store, content)
+}
+
+// prefixCache holds the prefixes guilds have set so messages can be matched
+// against them without reading the store. It is filled on first use.
+type prefixCache struct {
+	mu       sync.Mutex
+	loaded   bool
+	prefixes map[string]string
+}
+
+// newPrefixCache creates an empty cache
+func newPrefixCache() *prefixCache {
+	return &prefixCache{prefixes: make(map[string]string)}
+}
+
+// load reads every guild's prefix unless that has been done. The caller must
+// hold mu.
+func (pc *prefixCache) load(store Store) {
+	if pc.loaded {
+		return
+	}
+	guilds, err := store.Guilds()
+	if err != nil {
+		log.Printf("Error loading prefixes: %s\n", err.Error())
+		return
+	}
+	for _, guildID := range guilds {
+		prefix, err := store.Setting(guildID, prefixKey)
+		if err != nil {
+			log.Printf("Error loading prefix for guild %v: %s\n", guildID, err.Error())
+			return
+		}
+		if len(prefix) > 0 {
+			pc.prefixes[guildID] = string(prefix)
+		}
+	}
+	pc.loaded = true
+}
+
+// get returns the guild's prefix, or "" if it uses the default
+func (pc *prefixCache) get(store Store, guildID string) string {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	pc.load(store)
+	return pc.prefixes[guildID]
+}
+
+// matchAny reports whether content starts with any guild's prefix
+func (pc *prefixCache) matchAny(store Store, content string) bool {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	pc.load(store)
+	for _, prefix := range pc.prefixes {
+		if strings.HasPrefix(content, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// refresh rereads the guild's prefix after its settings change
+func (pc *prefixCache) refresh(store Store, guildID string) {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	if !pc.loaded {
+		return
+	}
+	prefix, err := store.Setting(guildID, prefixKey)
+	if err != nil || len(prefix) == 0 {
+		// Guilds that were archived or purged have no settings left
+		delete(pc.prefixes, guildID)
+		return
+	}
+	pc.prefixes[guildID] = string(prefix)
+}
+
+// botMention returns the mention of the bot content starts with, or "" if it
+// doesn't start with one
+func (b *Bot) botMention(content string) string {
+	if b.ID == "" {
+		return ""
+	}
+	// Members with a nickname are mentioned with a !
+	for _, mention := range []string{"<@" + b.ID + ">", "<@!" + b.ID + ">"} {
+		if strings.HasPrefix(content, mention) {
+			return mention
+		}
+	}
+	return ""
+}
+
+// validatePrefix explains what is wrong with a prefix, or returns "" if it
+// can be used
+func validatePrefix(prefix string) string {
+	switch {
+	case len([]rune(prefix)) > maxPrefixLength:
+		return fmt.Sprintf("Prefixes can be at most %v characters", maxPrefixLength)
+	case strings.ContainsAny(prefix, " \t\n`"):
+		return "Prefixes can't contain spaces or backticks"
+	case strings.HasPrefix(prefix, "<"):
+		// Mentions and channel links start with <
+		return "Prefixes can't start with <"
+	}
+	return ""
+}
+
+// Sets or shows the prefix commands start with on the server
+func setPrefix(ctx *Context) error {
+	channel, err := getChannel(ctx.Session, ctx.Message.ChannelID)
+	if err != nil {
+		return err
+	}
+
+	prefix := ctx.Arg("prefix")
+	switch {
+	case prefix == "":
+		ctx.Reply(fmt.Sprintf("Commands start with `%v`, e.g. `%vnextevent`", ctx.Prefix, ctx.Prefix))
+		return nil
+	case strings.ToLower(prefix) == "reset":
+		if err := ctx.Bot.Store.DeleteSetting(channel.GuildID, prefixKey); err != nil {
+			return err
+		}
+		ctx.Bot.Prefixes.refresh(ctx.Bot.Store, channel.GuildID)
+		ctx.Reply(fmt.Sprintf("Commands start with the default `%v` again", ctx.Bot.Config.Prefix))
+		ctx.Bot.guildLog(ctx.Session, channel.GuildID, "%v reset the command prefix to `%v`",
+			ctx.Message.Author.Username, ctx.Bot.Config.Prefix)
+		return nil
+	}
+
+	if problem := validatePrefix(prefix); problem != "" {
+		ctx.Reply(problem)
+		return nil
+	}
+	if err := ctx.Bot.Store.PutSetting(channel.GuildID, prefixKey, []byte(prefix)); err != nil {
+		return err
+	}
+	ctx.Bot.Prefixes.refresh(ctx.Bot.Store, channel.GuildID)
+	ctx.Reply(fmt.Sprintf("Commands now start with `%v`, e.g. `%vnextevent`", prefix, prefix))
+	ctx.Bot.guildLog(ctx.Session, channel.GuildID, "%v set the command prefix to `%v`", ctx.Message.Author.Username, prefix)
+	return nil
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPrefix(t *testing.T) {
+	t.Parallel()
+	customPrefix := func(t *testing.T, f *fakeSession) {
+		f.putSetting(t, prefixKey, "?")
+	}
+
+	runCommandTests(t, []commandTest{
+		{
+			name:     "show default",
+			commands: []string{"!prefix"},
+			want:     []sentMessage{reply("Commands start with `!`, e.g. `!nextevent`")},
+		},
+		{
+			name: "set",
+			setup: func(t *testing.T, f *fakeSession) {
+				f.putSetting(t, logChannelKey, testLogID)
+			},
+			commands: []string{"!prefix ?"},
+			want: []sentMessage{
+				reply("Commands now start with `?`, e.g. `?nextevent`"),
+				logged("admin set the command prefix to `?`"),
+			},
+		},
+		{
+			name:     "custom prefix used",
+			setup:    customPrefix,
+			author:   testUserID,
+			commands: []string{"?next"},
+			want:     []sentMessage{reply("Run ?setgroup first")},
+		},
+		{
+			name:     "default prefix ignored",
+			setup:    customPrefix,
+			author:   testUserID,
+			commands: []string{"!next"},
+			want:     nil,
+		},
+		{
+			name:     "reset",
+			setup:    customPrefix,
+			commands: []string{"?prefix reset", "!prefix"},
+			want:     []sentMessage{reply("Commands start with `!`, e.g. `!nextevent`")},
+		},
+		{
+			name:     "too long",
+			commands: []string{"!prefix bot..."},
+			want:     []sentMessage{reply("Prefixes can be at most 5 characters")},
+		},
+		{
+			name:     "backtick",
+			commands: []string{"!prefix `"},
+			want:     []sentMessage{reply("Prefixes can't contain spaces or backticks")},
+		},
+		{
+			name:     "mention",
+			commands: []string{"!prefix <#1>"},
+			want:     []sentMessage{reply("Prefixes can't start with <")},
+		},
+		{
+			name:     "denied",
+			author:   testUserID,
+			commands: []string{"!prefix ?"},
+			want:     denied("prefix"),
+		},
+		{
+			name:     "denied with custom prefix",
+			setup:    customPrefix,
+			author:   testUserID,
+			commands: []string{"?prefix !"},
+			want: []sentMessage{reply(
+				"You need the Manage Server permission or an allowed role to run `?prefix`")},
+		},
+		{
+			name:     "configured default",
+			setup:    func(t *testing.T, f *fakeSession) { f.bot.Config.Prefix = "." },
+			author:   testUserID,
+			commands: []string{".nextevent"},
+			want:     []sentMessage{reply("Run .setgroup first")},
+		},
+	})
+}
+
+func TestMentionInvokes(t *testing.T) {
+	t.Parallel()
+	runCommandTests(t, []commandTest{
+		{
+			name:     "mention",
+			author:   testUserID,
+			commands: []string{"<@bot> nextevent"},
+			want:     []sentMessage{reply("Run !setgroup first")},
+		},
+		{
+			name:     "nickname mention",
+			author:   testUserID,
+			commands: []string{"<@!bot>next"},
+			want:     []sentMessage{reply("Run !setgroup first")},
+		},
+		{
+			name: "mention with custom prefix",
+			setup: func(t *testing.T, f *fakeSession) {
+				f.putSetting(t, prefixKey, "?")
+			},
+			author:   testUserID,
+			commands: []string{"<@bot> nextevent"},
+			want:     []sentMessage{reply("Run ?setgroup first")},
+		},
+		{
+			name:     "mention alone",
+			author:   testUserID,
+			commands: []string{"<@bot>"},
+			want:     []sentMessage{reply("Commands here start with `!` or a mention of me, e.g. `!nextevent`")},
+		},
+		{
+			name:     "other mentions ignored",
+			author:   testUserID,
+			commands: []string{"<@someone> nextevent"},
+			want:     nil,
+		},
+		{
+			name:     "mention in private channel",
+			channel:  "dm",
+			commands: []string{"<@bot> prefix ?"},
+			want:     []sentMessage{{"dm", "You need the Manage Server permission or an allowed role to run `!prefix`"}},
+		},
+	})
+}
+
+func TestChatterSkipsLookups(t *testing.T) {
+	t.Parallel()
+	f := setupTest(t)
+	f.putSetting(t, prefixKey, "?")
+	tests := []struct {
+		content string
+		lookup  bool
+	}{
+		{"hello there", false},
+		{"see <@bot> later", false},
+		{"?next", true},
+		{"<@bot> next", true},
+		// The default prefix could be in use in a guild not seen yet
+		{"!next", true},
+	}
+	for _, test := range tests {
+		f.Lookups = make(map[string]int)
+		f.run(testUserID, testChannelID, test.content)
+		if looked := f.Lookups["Channel"] > 0; looked != test.lookup {
+			t.Errorf("%q looked up the channel %v times", test.content, f.Lookups["Channel"])
+		}
+	}
+}
+
+func TestPrefixChanges(t *testing.T) {
+	t.Parallel()
+	f := setupTest(t)
+	step := func(content string, want ...sentMessage) {
+		f.Sent = nil
+		f.run(testAdminID, testChannelID, content)
+		if !reflect.DeepEqual(f.Sent, want) {
+			t.Errorf("%v sent:\n%v\nwant:\n%v", content, formatSent(f.Sent), formatSent(want))
+		}
+	}
+
+	step("!prefix ?", reply("Commands now start with `?`, e.g. `?nextevent`"))
+	step("?next", reply("Run ?setgroup first"))
+	step("!next")
+
+	// The prefix leaves and comes back with the rest of the settings
+	f.bot.leaveGuild(f, testGuild(f, false))
+	f.bot.joinGuild(f, testGuild(f, false))
+	step("?next", reply("Run ?setgroup first"))
+
+	step("?prefix reset", reply("Commands start with the default `!` again"))
+	step("?next")
+	step("!next", reply("Run !setgroup first"))
+}
@@ -0,0 +1,337 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/bwmarrin/discordgo"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// remindersKey holds the guild's reminder offsets, e.g. ["1w","1d","1h"]
+	remindersKey = "reminders"
+	// reminderInterval is how often pending reminders are checked
+	reminderInterval = time.Minute
+)
+
+// offsetUnits are the units accepted in reminder offsets, largest first
+var offsetUnits = []struct {
+	suffix string
+	name   string
+	length time.Duration
+}{
+	{"w", "week", 7 * 24 * time.Hour},
+	{"d", "day", 24 * time.Hour},
+	{"h", "hour", time.Hour},
+	{"m", "minute", time.Minute},
+}
+
+func init() {
+	router.Register(&Command{
+		Name:        "reminders",
+		Description: "Sets how long before an event reminders are posted",
+		Usage:       "[offsets...|off]",
+		Args:        []Arg{{Name: "offsets", Optional: true, Rest: true}},
+		Permission:  discordgo.PermissionManageServer,
+		Examples:    []string{"", "1w 1d 1h", "off"},
+		Handler:     setReminders,
+	})
+}
+
+// parseOffset parses offsets such as "1w", "2d" or "1h30m"
+func parseOffset(str string) (time.Duration, error) {
+	var total time.Duration
+	rest := strings.ToLower(str)
+	for rest != "" {
+		i := 0
+		for i < len(rest) && rest[i] >= '0' && rest[i] <= '9' {
+			i++
+		}
+		if i == 0 || i == len(rest) {
+			return 0, fmt.Errorf("Invalid offset %v, use e.g. 1w, 2d, 1h30m", str)
+		}
+		n, err := strconv.Atoi(rest[:i])
+		if err != nil {
+			return 0, err
+		}
+
+		found := false
+		for _, unit := range offsetUnits {
+			if strings.HasPrefix(rest[i:], unit.suffix) {
+				total += time.Duration(n) * unit.length
+				rest = rest[i+len(unit.suffix):]
+				found = true
+				break
+			}
+		}
+		if !found {
+			return 0, fmt.Errorf("Invalid offset %v, use e.g. 1w, 2d, 1h30m", str)
+		}
+	}
+	if total <= 0 {
+		return 0, fmt.Errorf("Offset %v must be greater than zero", str)
+	}
+	return total, nil
+}
+
+// formatOffset describes an offset in words, e.g. "1 day 12 hours"
+func formatOffset(d time.Duration) string {
+	var parts []string
+	for _, unit := range offsetUnits {
+		n := d / unit.length
+		if n == 0 {
+			continue
+		}
+		d -= n * unit.length
+		name := unit.name
+		if n != 1 {
+			name += "s"
+		}
+		parts = append(parts, fmt.Sprintf("%v %v", int64(n), name))
+	}
+	return strings.Join(parts, " ")
+}
+
+// roundOffset describes d in its largest whole unit, e.g. "3 days"
+func roundOffset(d time.Duration) string {
+	for _, unit := range offsetUnits {
+		if d >= unit.length {
+			return formatOffset((d + unit.length/2) / unit.length * unit.length)
+		}
+	}
+	return "less than a minute"
+}
+
+// getReminderOffsets returns the guild's reminder offsets, largest first
+func (b *Bot) getReminderOffsets(guildID string) ([]time.Duration, error) {
+	var strs []string
+	if err := b.getGuildJSON(guildID, remindersKey, &strs); err != nil {
+		return nil, err
+	}
+	var offsets []time.Duration
+	for _, str := range strs {
+		offset, err := parseOffset(str)
+		if err != nil {
+			return nil, err
+		}
+		offsets = append(offsets, offset)
+	}
+	sort.Sort(sort.Reverse(durations(offsets)))
+	return offsets, nil
+}
+
+// durations sorts a slice of time.Duration ascending
+type durations []time.Duration
+
+func (d durations) Len() int           { return len(d) }
+func (d durations) Less(i, j int) bool { return d[i] < d[j] }
+func (d durations) Swap(i, j int)      { d[i], d[j] = d[j], d[i] }
+
+// startReminders posts due reminders every reminderInterval until ctx is
+// cancelled
+func (b *Bot) startReminders(ctx context.Context, s Session) {
+	if !b.Work.Start() {
+		return
+	}
+	go func() {
+		defer b.Work.Done()
+		ticker := time.NewTicker(reminderInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			guilds, err := b.Store.Guilds()
+			if err != nil {
+				log.Printf("Error listing guilds: %s\n", err.Error())
+				continue
+			}
+			for _, guildID := range guilds {
+				if ctx.Err() != nil {
+					return
+				}
+				if err := b.sendReminders(s, guildID, time.Now()); err != nil {
+					b.Status.recordError("reminders")
+					log.Printf("Error sending reminders for guild %v: %s\n", guildID, err.Error())
+				}
+			}
+		}
+	}()
+}
+
+// sendReminders posts a reminder for every tracked event with a reminder due
+// at now. When several of an event's offsets are due at once, e.g. after the
+// bot was offline, only the closest one is posted. Sent offsets are recorded
+// so restarts don't repeat them.
+func (b *Bot) sendReminders(s Session, guildID string, now time.Time) error {
+	offsets, err := b.getReminderOffsets(guildID)
+	if err != nil || len(offsets) == 0 {
+		return err
+	}
+	channelID, err := b.Store.Setting(guildID, announceChannelKey)
+	if err != nil {
+		return err
+	}
+	reminderChannelID := b.guildChannel(guildID, reminderChannelKey)
+	routes, err := b.getGroupChannels(guildID)
+	if err != nil {
+		return err
+	}
+	groups, err := b.getGroups(guildID)
+	if err != nil {
+		return err
+	}
+
+	tracked, err := b.Store.TrackedEvents(guildID)
+	if err != nil {
+		return err
+	}
+	sent, err := b.Store.SentReminders(guildID)
+	if err != nil {
+		return err
+	}
+	ts := b.getTimeSettings(guildID)
+
+	remaining := make(map[string][]string)
+	for id, event := range tracked {
+		if len(sent[id]) > 0 {
+			remaining[id] = sent[id]
+		}
+		target := reminderChannelID
+		if target == "" {
+			target = groupChannel(routes, string(channelID), event.Group.URLName)
+		}
+		if target == "" || event.Visibility != "public" || event.Status != "upcoming" {
+			continue
+		}
+
+		due, label := dueReminders(msToTime(event.Time), now, offsets, sent[id])
+		if len(due) == 0 {
+			continue
+		}
+		data := eventData(event, ts)
+		data.Until = label
+		data.ShowGroup = len(groups) > 1
+		msg := b.renderEvent(guildID, "reminder", data)
+		if err := sendMessage(s, target, msg); err != nil {
+			b.Status.recordError("reminders")
+			log.Printf("Error sending reminder for event %v: %s\n", id, err.Error())
+			b.guildLog(s, guildID, "Couldn't post a reminder for `%v` in <#%v>: %s", event.Name, target, err.Error())
+			continue
+		}
+		remindersTotal.Inc()
+		for _, offset := range due {
+			remaining[id] = append(remaining[id], offset.String())
+		}
+	}
+
+	// Events no longer tracked have passed, so their records are dropped
+	return b.Store.PutSentReminders(guildID, remaining)
+}
+
+// dueReminders returns the offsets, largest first, of the reminders for an
+// event starting at start that are due at now and not in sent, along with how
+// long is left as the posted reminder should say
+func dueReminders(start, now time.Time, offsets []time.Duration, sent []string) ([]time.Duration, string) {
+	var due []time.Duration
+	for _, offset := range offsets {
+		if !now.Before(start.Add(-offset)) && now.Before(start) && !containsString(sent, offset.String()) {
+			due = append(due, offset)
+		}
+	}
+	if len(due) == 0 {
+		return nil, ""
+	}
+
+	// Offsets are largest first, so the last due one is the closest. If it
+	// is late, e.g. the offset was added after its time passed, say how long
+	// is really left instead.
+	closest := due[len(due)-1]
+	if left := start.Sub(now); left < closest-2*reminderInterval {
+		return due, roundOffset(left)
+	}
+	return due, formatOffset(closest)
+}
+
+// queuedReminders counts the reminders still to be posted for the guild's
+// tracked events as of now
+func (b *Bot) queuedReminders(guildID string, now time.Time) (int, error) {
+	offsets, err := b.getReminderOffsets(guildID)
+	if err != nil || len(offsets) == 0 {
+		return 0, err
+	}
+	tracked, err := b.Store.TrackedEvents(guildID)
+	if err != nil {
+		return 0, err
+	}
+	sent, err := b.Store.SentReminders(guildID)
+	if err != nil {
+		return 0, err
+	}
+
+	queued := 0
+	for id, event := range tracked {
+		if event.Visibility != "public" || event.Status != "upcoming" || !now.Before(msToTime(event.Time)) {
+			continue
+		}
+		for _, offset := range offsets {
+			if !containsString(sent[id], offset.String()) {
+				queued++
+			}
+		}
+	}
+	return queued, nil
+}
+
+// Sets or shows how long before an event reminders are posted
+func setReminders(ctx *Context) error {
+	channel, err := getChannel(ctx.Session, ctx.Message.ChannelID)
+	if err != nil {
+		return err
+	}
+
+	arg := ctx.Arg("offsets")
+	if arg == "" {
+		offsets, err := ctx.Bot.getReminderOffsets(channel.GuildID)
+		if err != nil {
+			return err
+		}
+		if len(offsets) == 0 {
+			ctx.Reply("Reminders are off")
+			return nil
+		}
+		var names []string
+		for _, offset := range offsets {
+			names = append(names, formatOffset(offset))
+		}
+		ctx.Reply("Reminders are posted " + strings.Join(names, ", ") + " before each event")
+		return nil
+	}
+
+	var strs []string
+	if strings.ToLower(arg) != "off" {
+		for _, str := range strings.Fields(arg) {
+			if _, err := parseOffset(str); err != nil {
+				ctx.Reply(err.Error())
+				return nil
+			}
+			strs = append(strs, strings.ToLower(str))
+		}
+	}
+
+	if err := ctx.Bot.putGuildJSON(channel.GuildID, remindersKey, strs); err != nil {
+		return err
+	}
+	if len(strs) == 0 {
+		ctx.Reply("Reminders are now off")
+		return nil
+	}
+	ctx.Reply("Reminders will be posted " + strings.Join(strs, ", ") + " before each event")
+	return nil
+}
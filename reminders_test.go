@@ -0,0 +1,99 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSetReminders(t *testing.T) {
+	t.Parallel()
+	runCommandTests(t, []commandTest{
+		{
+			name:     "off by default",
+			commands: []string{"!reminders"},
+			want:     []sentMessage{reply("Reminders are off")},
+		},
+		{
+			name:     "set",
+			commands: []string{"!reminders 1H 1d"},
+			want:     []sentMessage{reply("Reminders will be posted 1h, 1d before each event")},
+		},
+		{
+			name:     "show largest first",
+			commands: []string{"!reminders 1h30m 1w 1d", "!reminders"},
+			want:     []sentMessage{reply("Reminders are posted 1 week, 1 day, 1 hour 30 minutes before each event")},
+		},
+		{
+			name:     "off",
+			commands: []string{"!reminders 1d", "!reminders off", "!reminders"},
+			want:     []sentMessage{reply("Reminders are off")},
+		},
+		{
+			name:     "invalid offset",
+			commands: []string{"!reminders 1d soon"},
+			want:     []sentMessage{reply("Invalid offset soon, use e.g. 1w, 2d, 1h30m")},
+		},
+		{
+			name:     "zero offset",
+			commands: []string{"!reminders 0h"},
+			want:     []sentMessage{reply("Offset 0h must be greater than zero")},
+		},
+		{
+			name:     "denied",
+			author:   testUserID,
+			commands: []string{"!reminders off"},
+			want:     denied("reminders"),
+		},
+	})
+}
+
+func TestDueReminders(t *testing.T) {
+	t.Parallel()
+	start := time.Date(2019, 6, 4, 18, 30, 0, 0, time.UTC)
+	offsets := []time.Duration{7 * 24 * time.Hour, 24 * time.Hour, time.Hour}
+	tests := []struct {
+		name      string
+		now       time.Time
+		sent      []string
+		want      []time.Duration
+		wantLabel string
+	}{
+		{name: "none due", now: start.Add(-48 * time.Hour), sent: []string{"168h0m0s"}},
+		{
+			name:      "on time",
+			now:       start.Add(-24 * time.Hour),
+			sent:      []string{"168h0m0s"},
+			want:      []time.Duration{24 * time.Hour},
+			wantLabel: "1 day",
+		},
+		{
+			name:      "just after its time",
+			now:       start.Add(-24*time.Hour + 30*time.Second),
+			sent:      []string{"168h0m0s"},
+			want:      []time.Duration{24 * time.Hour},
+			wantLabel: "1 day",
+		},
+		{name: "already sent", now: start.Add(-24 * time.Hour), sent: []string{"168h0m0s", "24h0m0s"}},
+		{
+			name:      "several due at once",
+			now:       start.Add(-30 * time.Minute),
+			want:      offsets,
+			wantLabel: "30 minutes",
+		},
+		{
+			name:      "late",
+			now:       start.Add(-20 * time.Hour),
+			sent:      []string{"168h0m0s"},
+			want:      []time.Duration{24 * time.Hour},
+			wantLabel: "20 hours",
+		},
+		{name: "started", now: start, sent: []string{"168h0m0s"}},
+	}
+	for _, test := range tests {
+		due, label := dueReminders(start, test.now, offsets, test.sent)
+		if !reflect.DeepEqual(due, test.want) || label != test.wantLabel {
+			t.Errorf("%v: dueReminders() = %v, %q, want %v, %q", test.name, due, label, test.want, test.wantLabel)
+		}
+	}
+}
@@ -0,0 +1,344 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/bwmarrin/discordgo"
+	"log"
+	"strings"
+	"time"
+)
+
+// Arg describes a single positional argument a command accepts
+type Arg struct {
+	Name string
+	// Optional arguments may be left off the end of the command
+	Optional bool
+	// Rest swallows every remaining token, joined by single spaces.
+	// Only valid on the last argument.
+	Rest bool
+	// Raw makes a Rest argument keep the remaining text as it was typed,
+	// quotes and spacing included
+	Raw bool
+}
+
+// Command is a single chat command the bot responds to
+type Command struct {
+	Name        string
+	Aliases     []string
+	Description string
+	// Usage overrides the usage string generated from Args
+	Usage string
+	Args  []Arg
+	// Examples are arguments shown after the command name in help, e.g.
+	// "golang-chicago". An empty string shows the command on its own.
+	Examples []string
+	// Permission is the set of Discord permission bits a user needs to run
+	// the command. Zero lets anyone run it.
+	Permission int
+	Handler    func(ctx *Context) error
+}
+
+// UsageString returns how the command is invoked, e.g. `!setgroup <urlname>`
+func (cmd *Command) UsageString(prefix string) string {
+	if cmd.Usage != "" {
+		return prefix + cmd.Name + " " + cmd.Usage
+	}
+	parts := []string{prefix + cmd.Name}
+	for _, arg := range cmd.Args {
+		name := arg.Name
+		if arg.Rest {
+			name += "..."
+		}
+		if arg.Optional {
+			parts = append(parts, "["+name+"]")
+		} else {
+			parts = append(parts, "<"+name+">")
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// Context is handed to a command's handler for a single invocation. It is
+// done once shutdown gives up waiting for running commands.
+type Context struct {
+	context.Context
+	Bot     *Bot
+	Session Session
+	Message *discordgo.MessageCreate
+	Command *Command
+	// Prefix commands start with where this one was run, even if it was
+	// invoked by mentioning the bot
+	Prefix string
+	// Args maps each declared argument name to its parsed value
+	Args map[string]string
+	// RawArgs is the untokenized text following the command name
+	RawArgs string
+}
+
+// Arg returns the value of the named argument, or "" if it was not given
+func (ctx *Context) Arg(name string) string {
+	return ctx.Args[name]
+}
+
+// Usage replies with how the command should be invoked
+func (ctx *Context) Usage() {
+	ctx.Reply(fmt.Sprintf("Usage: `%v`", ctx.Command.UsageString(ctx.Prefix)))
+}
+
+// Reply sends a message to the channel the command was run in. Long messages
+// are split to fit Discord's limit. Nothing is sent once the context is done,
+// as whatever the handler found out was cut short.
+func (ctx *Context) Reply(msg string) {
+	if ctx.Err() != nil {
+		return
+	}
+	err := sendMessage(ctx.Session, ctx.Message.ChannelID, msg)
+	if err != nil {
+		log.Printf("Error sending message: %s\n", err.Error())
+	}
+}
+
+// Router tokenizes messages and dispatches them to registered commands
+type Router struct {
+	commands []*Command
+	lookup   map[string]*Command
+}
+
+// NewRouter creates a router with no commands
+func NewRouter() *Router {
+	return &Router{
+		lookup: make(map[string]*Command),
+	}
+}
+
+// Register adds a command to the router. Registering a name or alias twice
+// is a programming error and panics.
+func (r *Router) Register(cmd *Command) {
+	for _, name := range append([]string{cmd.Name}, cmd.Aliases...) {
+		if _, exists := r.lookup[name]; exists {
+			panic(fmt.Sprintf("command %q registered twice", name))
+		}
+		r.lookup[name] = cmd
+	}
+	r.commands = append(r.commands, cmd)
+}
+
+// Commands returns every registered command in registration order
+func (r *Router) Commands() []*Command {
+	return r.commands
+}
+
+// Find looks up a command by name or alias
+func (r *Router) Find(name string) *Command {
+	return r.lookup[strings.ToLower(name)]
+}
+
+// Dispatch runs the command contained in the message for b, if there is one.
+// Commands start with the guild's prefix, defaulting to the config's, or a
+// mention of the bot.
+func (r *Router) Dispatch(b *Bot, s Session, m *discordgo.MessageCreate) {
+	// Most messages are chatter, which shouldn't cost a channel lookup
+	if !b.mightBeCommand(m.Content, b.Config.Prefix) {
+		return
+	}
+	prefix := b.guildPrefix(s, m.ChannelID, b.Config.Prefix)
+	mention := b.botMention(m.Content)
+	var line string
+	switch {
+	case mention != "":
+		line = strings.TrimPrefix(m.Content, mention)
+	case strings.HasPrefix(m.Content, prefix):
+		line = strings.TrimPrefix(m.Content, prefix)
+	default:
+		return
+	}
+
+	if strings.TrimSpace(line) == "" {
+		if mention != "" {
+			// Being mentioned on its own is usually someone asking how to
+			// use the bot
+			s.ChannelMessageSend(m.ChannelID, fmt.Sprintf(
+				"Commands here start with `%v` or a mention of me, e.g. `%vnextevent`", prefix, prefix))
+		}
+		return
+	}
+
+	// Messages that only happen to start with the prefix, e.g. "!! 'sup",
+	// are ignored before anything else is parsed
+	name, rest := splitCommand(line)
+	cmd := r.Find(name)
+	if cmd == nil {
+		return
+	}
+	tokens, err := tokenize(rest)
+	if err != nil {
+		s.ChannelMessageSend(m.ChannelID, err.Error())
+		return
+	}
+
+	ctx := &Context{
+		Context: b.Context,
+		Bot:     b,
+		Session: s,
+		Message: m,
+		Command: cmd,
+		Prefix:  prefix,
+		RawArgs: strings.TrimSpace(rest),
+	}
+
+	allowed, err := b.canRun(s, m, cmd)
+	if err != nil {
+		log.Printf("Error checking permissions: %s\n", err.Error())
+		return
+	}
+	if !allowed {
+		commandsTotal.Inc(cmd.Name, "denied")
+		ctx.Reply(fmt.Sprintf("You need the %v permission or an allowed role to run `%v%v`",
+			permissionString(cmd.Permission), prefix, cmd.Name))
+		return
+	}
+
+	ctx.Args, err = parseArgs(cmd.Args, tokens, rest)
+	if err != nil {
+		commandsTotal.Inc(cmd.Name, "usage")
+		ctx.Usage()
+		return
+	}
+
+	b.Stats.Count(statCommands)
+	start := time.Now()
+	err = cmd.Handler(ctx)
+	commandDuration.Observe(time.Since(start), cmd.Name)
+	if err != nil {
+		commandsTotal.Inc(cmd.Name, "error")
+		b.Status.recordError("commands")
+		log.Printf("Error running %v%v: %s\n", prefix, cmd.Name, err.Error())
+		ctx.Reply(err.Error())
+		return
+	}
+	commandsTotal.Inc(cmd.Name, "ok")
+}
+
+// parseArgs matches tokens up with a command's declared arguments. raw is
+// the text the tokens came from, for Raw arguments.
+func parseArgs(args []Arg, tokens []string, raw string) (map[string]string, error) {
+	values := make(map[string]string)
+	for i, arg := range args {
+		if i >= len(tokens) {
+			if !arg.Optional {
+				return nil, fmt.Errorf("missing argument %v", arg.Name)
+			}
+			continue
+		}
+		if arg.Rest && arg.Raw {
+			values[arg.Name] = skipTokens(raw, i)
+			return values, nil
+		}
+		if arg.Rest {
+			values[arg.Name] = strings.Join(tokens[i:], " ")
+			return values, nil
+		}
+		values[arg.Name] = tokens[i]
+	}
+	if len(tokens) > len(args) {
+		return nil, fmt.Errorf("too many arguments")
+	}
+	return values, nil
+}
+
+// tokenize splits a command line on whitespace. Single or double quotes at the
+// start of a word group words into one token, and a backslash escapes the next
+// character.
+func tokenize(line string) ([]string, error) {
+	var tokens []string
+	var current []rune
+	var quote rune
+	inToken := false
+	escaped := false
+
+	for _, c := range line {
+		switch {
+		case escaped:
+			current = append(current, c)
+			escaped = false
+		case c == '\\':
+			escaped = true
+			inToken = true
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			} else {
+				current = append(current, c)
+			}
+		case (c == '"' || c == '\'') && !inToken:
+			quote = c
+			inToken = true
+		case isSpace(c):
+			if inToken {
+				tokens = append(tokens, string(current))
+				current = current[:0]
+				inToken = false
+			}
+		default:
+			current = append(current, c)
+			inToken = true
+		}
+	}
+
+	if quote != 0 {
+		return nil, fmt.Errorf("Unterminated %c quote", quote)
+	}
+	if inToken {
+		tokens = append(tokens, string(current))
+	}
+	return tokens, nil
+}
+
+// splitCommand separates the command name at the start of line from the
+// untokenized text after it. The name is unquoted and unescaped like any
+// other token, and is empty if it can't be parsed.
+func splitCommand(line string) (string, string) {
+	line = strings.TrimLeftFunc(line, isSpace)
+	end := len(line)
+	var quote rune
+	escaped := false
+scan:
+	for i, c := range line {
+		switch {
+		case escaped:
+			escaped = false
+		case c == '\\':
+			escaped = true
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case (c == '"' || c == '\'') && i == 0:
+			quote = c
+		case isSpace(c):
+			end = i
+			break scan
+		}
+	}
+
+	tokens, err := tokenize(line[:end])
+	if err != nil || len(tokens) != 1 {
+		return "", line[end:]
+	}
+	return tokens[0], line[end:]
+}
+
+// skipTokens returns the text of line after its first n tokens, with
+// surrounding whitespace trimmed
+func skipTokens(line string, n int) string {
+	for i := 0; i < n; i++ {
+		_, line = splitCommand(line)
+	}
+	return strings.TrimSpace(line)
+}
+
+// isSpace reports whether c separates tokens
+func isSpace(c rune) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"github.com/bwmarrin/discordgo"
+	"reflect"
+	"testing"
+)
+
+func TestTokenize(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		line string
+		want []string
+		err  string
+	}{
+		{"", nil, ""},
+		{"  setgroup\tgolang-chicago \n", []string{"setgroup", "golang-chicago"}, ""},
+		{`timeformat "Mon Jan 2" 'a b'`, []string{"timeformat", "Mon Jan 2", "a b"}, ""},
+		{`say "it's fine"`, []string{"say", "it's fine"}, ""},
+		{`say 'a "quoted" word'`, []string{"say", `a "quoted" word`}, ""},
+		// Quotes only group words when they start one
+		{`don't stop`, []string{"don't", "stop"}, ""},
+		{`a"b c"`, []string{`a"b`, `c"`}, ""},
+		{`one\ token \"two\"`, []string{"one token", `"two"`}, ""},
+		{`back\\slash`, []string{`back\slash`}, ""},
+		{`empty "" ''`, []string{"empty", "", ""}, ""},
+		{`say "unterminated`, nil, `Unterminated " quote`},
+		{`say 'sup`, nil, "Unterminated ' quote"},
+	}
+	for _, test := range tests {
+		got, err := tokenize(test.line)
+		if test.err != "" {
+			if err == nil || err.Error() != test.err {
+				t.Errorf("tokenize(%q) error = %v, want %v", test.line, err, test.err)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(got, test.want) {
+			t.Errorf("tokenize(%q) = %q, %v, want %q", test.line, got, err, test.want)
+		}
+	}
+}
+
+func TestParseArgs(t *testing.T) {
+	t.Parallel()
+	args := []Arg{{Name: "kind"}, {Name: "channel", Optional: true}, {Name: "rest", Optional: true, Rest: true}}
+	rawArgs := []Arg{{Name: "name"}, {Name: "text", Optional: true, Rest: true, Raw: true}}
+	tests := []struct {
+		args []Arg
+		line string
+		want map[string]string
+		ok   bool
+	}{
+		{args, "", nil, false},
+		{args, "announce", map[string]string{"kind": "announce"}, true},
+		{args, "announce #events", map[string]string{"kind": "announce", "channel": "#events"}, true},
+		{args, `a b  "c"   d`, map[string]string{"kind": "a", "channel": "b", "rest": "c d"}, true},
+		{rawArgs, "next", map[string]string{"name": "next"}, true},
+		{rawArgs, `"next" Up  "next" `, map[string]string{"name": "next", "text": `Up  "next"`}, true},
+		{rawArgs, `'a b'\ c  {{.Event.Name}}`, map[string]string{"name": `a b c`, "text": "{{.Event.Name}}"}, true},
+		{[]Arg{{Name: "urlname"}}, "golang chicago", nil, false},
+	}
+	for _, test := range tests {
+		tokens, err := tokenize(test.line)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := parseArgs(test.args, tokens, test.line)
+		if (err == nil) != test.ok || test.ok && !reflect.DeepEqual(got, test.want) {
+			t.Errorf("parseArgs(%q) = %v, %v, want %v", test.line, got, err, test.want)
+		}
+	}
+}
+
+func TestDispatchParsing(t *testing.T) {
+	t.Parallel()
+	// echo replies with the raw text after the command name and the tokens
+	// it was split into
+	r := NewRouter()
+	r.Register(&Command{
+		Name: "echo",
+		Args: []Arg{{Name: "words", Optional: true, Rest: true}},
+		Handler: func(ctx *Context) error {
+			ctx.Reply(ctx.RawArgs + "|" + ctx.Arg("words"))
+			return nil
+		},
+	})
+
+	tests := []struct {
+		content string
+		want    []sentMessage
+	}{
+		{`!echo "a  b"  c`, []sentMessage{reply(`"a  b"  c|a  b c`)}},
+		{`!"echo" "a  b"`, []sentMessage{reply(`"a  b"|a  b`)}},
+		{`!'ech'o x`, []sentMessage{reply(`x|x`)}},
+		{`<@bot>   echo x`, []sentMessage{reply(`x|x`)}},
+		{`!echo "unterminated`, []sentMessage{reply(`Unterminated " quote`)}},
+		// Chatter that happens to start with the prefix is ignored
+		{`!! 'sup`, nil},
+		{`!'sup`, nil},
+		{`!"echo x`, nil},
+		{`!echoes "x`, nil},
+	}
+	for _, test := range tests {
+		f := setupTest(t)
+		r.Dispatch(f.bot, f, &discordgo.MessageCreate{Message: &discordgo.Message{
+			ChannelID: testChannelID,
+			Content:   test.content,
+			Author:    &discordgo.User{ID: testUserID, Username: testUserID},
+		}})
+		if !reflect.DeepEqual(f.Sent, test.want) {
+			t.Errorf("%v sent:\n%v\nwant:\n%v", test.content, formatSent(f.Sent), formatSent(test.want))
+		}
+	}
+}
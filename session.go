@@ -0,0 +1,19 @@
+package main
+
+import (
+	"github.com/bwmarrin/discordgo"
+)
+
+// Session is the part of a Discord session the bot's handlers use, so they
+// can be run against a fake in tests
+type Session interface {
+	Channel(channelID string) (*discordgo.Channel, error)
+	ChannelMessageSend(channelID string, content string) (*discordgo.Message, error)
+	GuildChannels(guildID string) ([]*discordgo.Channel, error)
+	GuildMember(guildID, userID string) (*discordgo.Member, error)
+	GuildRoles(guildID string) ([]*discordgo.Role, error)
+	UserChannelPermissions(userID, channelID string) (int, error)
+}
+
+// Make sure the real session keeps satisfying Session
+var _ Session = (*discordgo.Session)(nil)
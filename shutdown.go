@@ -0,0 +1,56 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// shutdownTimeout is how long shutdown waits for in-flight work. Heroku kills
+// dynos 30 seconds after sending SIGTERM, so this leaves time to close up.
+const shutdownTimeout = 20 * time.Second
+
+// tracker is a WaitGroup that stops accepting work once closed. The bot's
+// tracker follows running command handlers and background jobs so shutdown
+// can wait for them to finish.
+type tracker struct {
+	mu     sync.Mutex
+	wg     sync.WaitGroup
+	closed bool
+}
+
+// Start records a piece of work starting. It returns false once the tracker
+// is closed, in which case the work should be skipped and Done not called.
+func (t *tracker) Start() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		return false
+	}
+	t.wg.Add(1)
+	return true
+}
+
+// Done records a piece of work finishing
+func (t *tracker) Done() {
+	t.wg.Done()
+}
+
+// Close stops new work from starting and waits up to timeout for running
+// work to finish. It reports whether everything finished in time.
+func (t *tracker) Close(timeout time.Duration) bool {
+	t.mu.Lock()
+	t.closed = true
+	t.mu.Unlock()
+
+	finished := make(chan struct{})
+	go func() {
+		t.wg.Wait()
+		close(finished)
+	}()
+	select {
+	case <-finished:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
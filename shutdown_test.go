@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestTrackerWaits(t *testing.T) {
+	t.Parallel()
+	tr := &tracker{}
+	if !tr.Start() {
+		t.Fatal("Start refused work before Close")
+	}
+	finished := false
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		finished = true
+		tr.Done()
+	}()
+
+	if !tr.Close(time.Second) {
+		t.Fatal("Close timed out")
+	}
+	if !finished {
+		t.Error("Close returned before the work finished")
+	}
+	if tr.Start() {
+		t.Error("Start accepted work after Close")
+	}
+}
+
+func TestTrackerTimesOut(t *testing.T) {
+	t.Parallel()
+	tr := &tracker{}
+	tr.Start()
+	start := time.Now()
+	if tr.Close(10 * time.Millisecond) {
+		t.Error("Close reported running work as finished")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Close took %v", elapsed)
+	}
+}
+
+func TestBackgroundJobsStop(t *testing.T) {
+	t.Parallel()
+	f := setupTest(t)
+	setupMeetup(t, f)
+	f.putSetting(t, groupsKey, `["golang-chicago"]`)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	f.bot.startPoller(ctx, f, time.Hour)
+	f.bot.startReminders(ctx, f)
+	cancel()
+	if !f.bot.Work.Close(time.Second) {
+		t.Fatal("background jobs still running after shutdown")
+	}
+}
+
+func TestPollGuildsCancelled(t *testing.T) {
+	t.Parallel()
+	f := setupTest(t)
+	fm := setupMeetup(t, f)
+	f.putSetting(t, groupsKey, `["golang-chicago"]`)
+	f.putSetting(t, announceChannelKey, "announcements")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	f.bot.pollGuilds(ctx, f)
+	if n := fm.requestCount(); n != 0 {
+		t.Errorf("made %v Meetup requests after shutdown started", n)
+	}
+
+	f.bot.pollGuilds(context.Background(), f)
+	if fm.requestCount() == 0 {
+		t.Error("made no Meetup requests")
+	}
+}
+
+func TestPollGuildCancelledQuietly(t *testing.T) {
+	t.Parallel()
+	f := setupTest(t)
+	setupMeetup(t, f)
+	f.putSetting(t, groupsKey, `["golang-chicago"]`)
+	f.putSetting(t, announceChannelKey, "announcements")
+	f.putSetting(t, logChannelKey, testLogID)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := f.bot.pollGuild(ctx, f, testGuildID); err != nil {
+		t.Errorf("pollGuild() = %v after shutdown started", err)
+	}
+	if len(f.Sent) != 0 {
+		t.Errorf("sent during shutdown:\n%v", formatSent(f.Sent))
+	}
+	if errors := f.bot.Status.errors; len(errors) != 0 {
+		t.Errorf("recorded errors %v during shutdown", errors)
+	}
+}
+
+func TestCommandsCancelledQuietly(t *testing.T) {
+	t.Parallel()
+	f := setupTest(t)
+	setupMeetup(t, f)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	f.bot.Context = ctx
+
+	for _, command := range []string{"!nextevent", "!setgroup golang-chicago"} {
+		f.run(testAdminID, testChannelID, command)
+		if len(f.Sent) != 0 {
+			t.Errorf("%v replied after shutdown gave up on it:\n%v", command, formatSent(f.Sent))
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// StatHat stat names
+const (
+	statCommands  = "meetup-bot commands run"
+	statAnnounced = "meetup-bot events announced"
+	statMeetupErr = "meetup-bot meetup errors"
+)
+
+// stathatURL is StatHat's EZ API endpoint
+const stathatURL = "https://api.stathat.com/ez"
+
+// statFlushInterval is how often counts are sent to the stats sink
+const statFlushInterval = time.Minute
+
+// statSink is somewhere counts are reported to
+type statSink interface {
+	// Send reports counts by stat name, accumulated since the last Send
+	Send(counts map[string]int) error
+}
+
+// statReporter totals counts in memory and sends them to a sink in batches.
+// A nil reporter drops every count.
+type statReporter struct {
+	sink statSink
+
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+// newStatReporter creates a reporter sending to sink
+func newStatReporter(sink statSink) *statReporter {
+	return &statReporter{sink: sink, counts: make(map[string]int)}
+}
+
+// Count adds one to stat
+func (r *statReporter) Count(stat string) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.counts[stat]++
+}
+
+// Flush sends the counts gathered since the last flush. Counts that fail to
+// send are kept for the next one.
+func (r *statReporter) Flush() error {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	counts := r.counts
+	r.counts = make(map[string]int)
+	r.mu.Unlock()
+	if len(counts) == 0 {
+		return nil
+	}
+
+	err := r.sink.Send(counts)
+	if err != nil {
+		r.mu.Lock()
+		for stat, n := range counts {
+			r.counts[stat] += n
+		}
+		r.mu.Unlock()
+	}
+	return err
+}
+
+// Start flushes every interval until ctx is cancelled, then flushes once more
+// so counts from the last interval aren't lost. The flushing runs under work.
+func (r *statReporter) Start(ctx context.Context, work *tracker, interval time.Duration) {
+	if r == nil || !work.Start() {
+		return
+	}
+	go func() {
+		defer work.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				if err := r.Flush(); err != nil {
+					log.Printf("Error sending stats: %s\n", err.Error())
+				}
+				return
+			case <-ticker.C:
+				if err := r.Flush(); err != nil {
+					log.Printf("Error sending stats: %s\n", err.Error())
+				}
+			}
+		}
+	}()
+}
+
+// stathatSink sends counts to StatHat's EZ API
+type stathatSink struct {
+	URL        string
+	EZKey      string
+	HTTPClient *http.Client
+}
+
+// stathatStat is a single count in an EZ API request
+type stathatStat struct {
+	Stat  string `json:"stat"`
+	Count int    `json:"count"`
+}
+
+// stathatRequest is the body of an EZ API request
+type stathatRequest struct {
+	EZKey string        `json:"ezkey"`
+	Data  []stathatStat `json:"data"`
+}
+
+// newStathatSink creates a sink for the EZ key, usually the account's email
+func newStathatSink(ezKey string) *stathatSink {
+	return &stathatSink{
+		URL:        stathatURL,
+		EZKey:      ezKey,
+		HTTPClient: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Send posts counts to StatHat in a single request
+func (ss *stathatSink) Send(counts map[string]int) error {
+	body := stathatRequest{EZKey: ss.EZKey}
+	for stat, n := range counts {
+		body.Data = append(body.Data, stathatStat{stat, n})
+	}
+	sort.Slice(body.Data, func(i, j int) bool { return body.Data[i].Stat < body.Data[j].Stat })
+
+	encoded, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	resp, err := ss.HTTPClient.Post(ss.URL, "application/json", bytes.NewReader(encoded))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Status int    `json:"status"`
+		Msg    string `json:"msg"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil || resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("stathat: %v", resp.Status)
+	}
+	if result.Status != http.StatusOK {
+		return fmt.Errorf("stathat: %v", result.Msg)
+	}
+	return nil
+}
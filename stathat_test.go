@@ -0,0 +1,201 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordingSink is a statSink that keeps every batch sent to it
+type recordingSink struct {
+	mu      sync.Mutex
+	batches []map[string]int
+	err     error
+}
+
+func (rs *recordingSink) Send(counts map[string]int) error {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	if rs.err != nil {
+		return rs.err
+	}
+	rs.batches = append(rs.batches, counts)
+	return nil
+}
+
+// newFakeStatHat starts a stand-in for StatHat's EZ API that accepts testKey
+// and records each request body
+func newFakeStatHat(t *testing.T) (*httptest.Server, *[]stathatRequest) {
+	var mu sync.Mutex
+	var received []stathatRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body stathatRequest
+		if r.Method != "POST" || r.Header.Get("Content-Type") != "application/json" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if body.EZKey != "stats@example.com" {
+			json.NewEncoder(w).Encode(map[string]interface{}{"status": 500, "msg": "invalid ezkey"})
+			return
+		}
+		mu.Lock()
+		received = append(received, body)
+		mu.Unlock()
+		json.NewEncoder(w).Encode(map[string]interface{}{"status": 200, "msg": "ok"})
+	}))
+	t.Cleanup(server.Close)
+	return server, &received
+}
+
+func TestStathatSink(t *testing.T) {
+	t.Parallel()
+	server, received := newFakeStatHat(t)
+	sink := newStathatSink("stats@example.com")
+	sink.URL = server.URL
+
+	if err := sink.Send(map[string]int{statCommands: 3, statAnnounced: 1}); err != nil {
+		t.Fatal(err)
+	}
+	want := []stathatRequest{{
+		EZKey: "stats@example.com",
+		Data:  []stathatStat{{statCommands, 3}, {statAnnounced, 1}},
+	}}
+	if !reflect.DeepEqual(*received, want) {
+		t.Errorf("received %+v, want %+v", *received, want)
+	}
+
+	sink.EZKey = "wrong@example.com"
+	if err := sink.Send(map[string]int{statCommands: 1}); err == nil || err.Error() != "stathat: invalid ezkey" {
+		t.Errorf("got error %v, want invalid ezkey", err)
+	}
+	broken := httptest.NewServer(http.NotFoundHandler())
+	defer broken.Close()
+	sink.URL = broken.URL
+	sink.EZKey = "stats@example.com"
+	if err := sink.Send(map[string]int{statCommands: 1}); err == nil {
+		t.Error("got no error from a bad response")
+	}
+}
+
+func TestStatReporterFlush(t *testing.T) {
+	t.Parallel()
+	sink := &recordingSink{}
+	r := newStatReporter(sink)
+	if err := r.Flush(); err != nil || len(sink.batches) != 0 {
+		t.Errorf("empty flush sent %v, %v", sink.batches, err)
+	}
+
+	r.Count(statCommands)
+	r.Count(statCommands)
+	sink.err = errors.New("unreachable")
+	if err := r.Flush(); err == nil {
+		t.Error("got no error from a failing sink")
+	}
+	// Counts that failed to send go out with the next flush
+	sink.err = nil
+	r.Count(statCommands)
+	if err := r.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if want := []map[string]int{{statCommands: 3}}; !reflect.DeepEqual(sink.batches, want) {
+		t.Errorf("sent %v, want %v", sink.batches, want)
+	}
+
+	var disabled *statReporter
+	disabled.Count(statCommands)
+	if err := disabled.Flush(); err != nil {
+		t.Errorf("disabled reporter returned %v", err)
+	}
+}
+
+func TestStatReporterFlushesOnShutdown(t *testing.T) {
+	t.Parallel()
+	f := setupTest(t)
+	sink := &recordingSink{}
+	f.bot.Stats = newStatReporter(sink)
+	ctx, cancel := context.WithCancel(context.Background())
+	f.bot.Stats.Start(ctx, f.bot.Work, time.Hour)
+
+	f.bot.Stats.Count(statAnnounced)
+	cancel()
+	if !f.bot.Work.Close(time.Second) {
+		t.Fatal("reporter still running after shutdown")
+	}
+	if want := []map[string]int{{statAnnounced: 1}}; !reflect.DeepEqual(sink.batches, want) {
+		t.Errorf("sent %v, want %v", sink.batches, want)
+	}
+}
+
+func TestStatsCounted(t *testing.T) {
+	t.Parallel()
+	f := setupTest(t)
+	fm := setupMeetup(t, f)
+	sink := &recordingSink{}
+	f.bot.Stats = newStatReporter(sink)
+	f.putSetting(t, groupsKey, `["golang-chicago"]`)
+	f.putSetting(t, announceChannelKey, "announcements")
+	if err := f.bot.pollGuild(context.Background(), f, testGuildID); err != nil {
+		t.Fatal(err)
+	}
+
+	events := fm.getEvents("golang-chicago")
+	added := events[0]
+	added.ID = "250000006"
+	fm.setEvents("golang-chicago", append(events, added))
+	if err := f.bot.pollGuild(context.Background(), f, testGuildID); err != nil {
+		t.Fatal(err)
+	}
+	f.run(testUserID, testChannelID, "!next")
+	// Denied commands aren't run
+	f.run(testUserID, testChannelID, "!setgroup golang-atlantis")
+	f.run(testAdminID, testChannelID, "!setgroup golang-atlantis")
+
+	if err := f.bot.Stats.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	// The mistyped urlname isn't a Meetup error
+	want := []map[string]int{{statCommands: 2, statAnnounced: 1}}
+	if !reflect.DeepEqual(sink.batches, want) {
+		t.Errorf("sent %v, want %v", sink.batches, want)
+	}
+}
+
+func TestMeetupErrorsCounted(t *testing.T) {
+	t.Parallel()
+	f := setupTest(t)
+	sink := &recordingSink{}
+	f.bot.Stats = newStatReporter(sink)
+	requests := []struct {
+		endpoint string
+		status   int
+	}{
+		{"events", http.StatusOK},
+		{"group", http.StatusNotModified},
+		{"group", http.StatusNotFound},
+		{"events", http.StatusNotFound},
+		{"events", http.StatusTooManyRequests},
+		{"group", http.StatusInternalServerError},
+		// The request never got a response
+		{"events", 0},
+	}
+	for _, r := range requests {
+		f.bot.recordMeetupRequest(r.endpoint, r.status, time.Millisecond)
+	}
+	if err := f.bot.Stats.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	want := []map[string]int{{statMeetupErr: 4}}
+	if !reflect.DeepEqual(sink.batches, want) {
+		t.Errorf("sent %v, want %v", sink.batches, want)
+	}
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"encoding/json"
+	"github.com/bwmarrin/discordgo"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// botStatus is the state of the bot's connections and background jobs
+type botStatus struct {
+	mu      sync.Mutex
+	started time.Time
+	// connected is set while the Discord websocket is open
+	connected bool
+	// ready is set once startup finishes and cleared when shutdown starts
+	ready bool
+	// lastPoll is when each group's events were last fetched
+	lastPoll map[string]time.Time
+	// errors counts errors by where they happened, e.g. "poll"
+	errors map[string]int
+}
+
+// statusReport is the JSON served on /status
+type statusReport struct {
+	Connected       bool                 `json:"connected"`
+	Ready           bool                 `json:"ready"`
+	Started         time.Time            `json:"started"`
+	Uptime          string               `json:"uptime"`
+	Guilds          int                  `json:"guilds"`
+	LastPoll        map[string]time.Time `json:"lastPoll"`
+	QueuedReminders int                  `json:"queuedReminders"`
+	Errors          map[string]int       `json:"errors"`
+}
+
+// newBotStatus creates the status for a bot starting now
+func newBotStatus() *botStatus {
+	return &botStatus{
+		started:  time.Now(),
+		lastPoll: make(map[string]time.Time),
+		errors:   make(map[string]int),
+	}
+}
+
+// setConnected records whether the Discord websocket is open
+func (bs *botStatus) setConnected(connected bool) {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	bs.connected = connected
+}
+
+// setReady records whether the bot is ready to handle commands
+func (bs *botStatus) setReady(ready bool) {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	bs.ready = ready
+}
+
+// state returns whether the websocket is connected and the bot is ready
+func (bs *botStatus) state() (connected, ready bool) {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	return bs.connected, bs.ready
+}
+
+// recordPoll records a group's events being fetched at t
+func (bs *botStatus) recordPoll(group string, t time.Time) {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	bs.lastPoll[group] = t
+}
+
+// recordError counts an error that happened in kind, e.g. "poll"
+func (bs *botStatus) recordError(kind string) {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	bs.errors[kind]++
+}
+
+// report collects the bot's status as of now
+func (b *Bot) report(now time.Time) (statusReport, error) {
+	guilds, err := b.Store.Guilds()
+	if err != nil {
+		return statusReport{}, err
+	}
+	queued := 0
+	for _, guildID := range guilds {
+		n, err := b.queuedReminders(guildID, now)
+		if err != nil {
+			return statusReport{}, err
+		}
+		queued += n
+	}
+
+	bs := b.Status
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	report := statusReport{
+		Connected:       bs.connected,
+		Ready:           bs.ready,
+		Started:         bs.started,
+		Uptime:          now.Sub(bs.started).Round(time.Second).String(),
+		Guilds:          len(guilds),
+		LastPoll:        make(map[string]time.Time),
+		QueuedReminders: queued,
+		Errors:          make(map[string]int),
+	}
+	for group, t := range bs.lastPoll {
+		report.LastPoll[group] = t
+	}
+	for kind, n := range bs.errors {
+		report.Errors[kind] = n
+	}
+	return report, nil
+}
+
+// sessionConnect is called when the Discord websocket opens
+func (b *Bot) sessionConnect(s *discordgo.Session, c *discordgo.Connect) {
+	b.Status.setConnected(true)
+}
+
+// sessionDisconnect is called when the Discord websocket closes
+func (b *Bot) sessionDisconnect(s *discordgo.Session, d *discordgo.Disconnect) {
+	b.Status.setConnected(false)
+}
+
+// statusHandler serves /healthz, /readyz, /status and /metrics
+func (b *Bot) statusHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", b.serveMetrics)
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		if connected, _ := b.Status.state(); !connected {
+			http.Error(w, "Discord websocket disconnected", http.StatusServiceUnavailable)
+			return
+		}
+		if _, err := b.Store.Guilds(); err != nil {
+			http.Error(w, "Settings unreachable: "+err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("ok\n"))
+	})
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		if connected, ready := b.Status.state(); !connected || !ready {
+			http.Error(w, "not ready", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("ok\n"))
+	})
+	mux.HandleFunc("/status", func(w http.ResponseWriter, r *http.Request) {
+		report, err := b.report(time.Now())
+		if err != nil {
+			log.Printf("Error building status: %s\n", err.Error())
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		enc.Encode(report)
+	})
+	return mux
+}
+
+// startStatusServer serves the status endpoints on addr in the background
+func (b *Bot) startStatusServer(addr string) *http.Server {
+	server := &http.Server{Addr: addr, Handler: b.statusHandler()}
+	go func() {
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Printf("Error serving status: %s\n", err.Error())
+		}
+	}()
+	return server
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// brokenStore is a Store that can't be read
+type brokenStore struct {
+	Store
+}
+
+func (brokenStore) Guilds() ([]string, error) {
+	return nil, errors.New("database not open")
+}
+
+// getStatus requests path from the test bot's status endpoints
+func getStatus(t *testing.T, f *fakeSession, path string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	f.bot.statusHandler().ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+	return rec
+}
+
+func TestHealthz(t *testing.T) {
+	t.Parallel()
+	f := setupTest(t)
+	if code := getStatus(t, f, "/healthz").Code; code != http.StatusServiceUnavailable {
+		t.Errorf("disconnected: got %v, want %v", code, http.StatusServiceUnavailable)
+	}
+
+	f.bot.Status.setConnected(true)
+	if code := getStatus(t, f, "/healthz").Code; code != http.StatusOK {
+		t.Errorf("connected: got %v, want %v", code, http.StatusOK)
+	}
+
+	f.bot.Store = brokenStore{f.bot.Store}
+	if code := getStatus(t, f, "/healthz").Code; code != http.StatusServiceUnavailable {
+		t.Errorf("broken store: got %v, want %v", code, http.StatusServiceUnavailable)
+	}
+}
+
+func TestReadyz(t *testing.T) {
+	t.Parallel()
+	f := setupTest(t)
+	f.bot.Status.setConnected(true)
+	if code := getStatus(t, f, "/readyz").Code; code != http.StatusServiceUnavailable {
+		t.Errorf("starting: got %v, want %v", code, http.StatusServiceUnavailable)
+	}
+
+	f.bot.Status.setReady(true)
+	if code := getStatus(t, f, "/readyz").Code; code != http.StatusOK {
+		t.Errorf("ready: got %v, want %v", code, http.StatusOK)
+	}
+
+	f.bot.Status.setConnected(false)
+	if code := getStatus(t, f, "/readyz").Code; code != http.StatusServiceUnavailable {
+		t.Errorf("disconnected: got %v, want %v", code, http.StatusServiceUnavailable)
+	}
+}
+
+func TestStatus(t *testing.T) {
+	t.Parallel()
+	f := setupTest(t)
+	setupMeetup(t, f)
+	f.putSetting(t, groupsKey, `["golang-chicago","golang-atlantis"]`)
+	f.putSetting(t, announceChannelKey, "announcements")
+	f.putSetting(t, remindersKey, `["1d","1h"]`)
+	if _, err := f.bot.Store.EnsureGuild("other"); err != nil {
+		t.Fatal(err)
+	}
+	f.bot.Status.setConnected(true)
+	f.bot.Status.setReady(true)
+
+	before := time.Now()
+	f.bot.pollGuilds(context.Background(), f)
+	// A reminder sent a day ahead leaves the hour one queued
+	if err := f.bot.sendReminders(f, testGuildID, msToTime(1906846200000).Add(-23*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	rec := getStatus(t, f, "/status")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("got %v: %v", rec.Code, rec.Body)
+	}
+	var report statusReport
+	if err := json.NewDecoder(rec.Body).Decode(&report); err != nil {
+		t.Fatal(err)
+	}
+
+	if !report.Connected || !report.Ready {
+		t.Errorf("connected %v, ready %v", report.Connected, report.Ready)
+	}
+	if report.Guilds != 2 {
+		t.Errorf("guilds = %v, want 2", report.Guilds)
+	}
+	if polled, ok := report.LastPoll["golang-chicago"]; !ok || polled.Before(before.Truncate(time.Second)) {
+		t.Errorf("lastPoll = %v, want golang-chicago polled since %v", report.LastPoll, before)
+	}
+	if _, ok := report.LastPoll["golang-atlantis"]; ok {
+		t.Errorf("lastPoll = %v, want no poll of the missing group", report.LastPoll)
+	}
+	// Three public upcoming events with two reminders each, one sent
+	if report.QueuedReminders != 5 {
+		t.Errorf("queuedReminders = %v, want 5", report.QueuedReminders)
+	}
+	if want := map[string]int{"meetup": 1}; !reflect.DeepEqual(report.Errors, want) {
+		t.Errorf("errors = %v, want %v", report.Errors, want)
+	}
+}
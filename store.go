@@ -0,0 +1,96 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/jaredkotoff/meetup-bot/meetup"
+	"sort"
+)
+
+// Store keeps the bot's state for each guild: its settings, the events it
+// tracks, the reminders already sent and a history of what the bot found
+type Store interface {
+	// Guilds returns the ID of every guild with settings
+	Guilds() ([]string, error)
+	// EnsureGuild creates a guild's settings if they don't exist, restoring
+	// them from the archive when there is one. It reports whether settings
+	// were restored.
+	EnsureGuild(guildID string) (bool, error)
+	// ArchiveGuild moves a guild's settings into the archive
+	ArchiveGuild(guildID string) error
+	// PurgeGuild deletes a guild's settings and any archived copy
+	PurgeGuild(guildID string) error
+
+	// Setting reads a single setting, empty if it isn't set
+	Setting(guildID, key string) ([]byte, error)
+	// Settings returns every setting of a guild
+	Settings(guildID string) (map[string][]byte, error)
+	// PutSetting writes a single setting
+	PutSetting(guildID, key string, value []byte) error
+	// DeleteSetting removes a single setting
+	DeleteSetting(guildID, key string) error
+
+	// TrackedEvents returns the last seen version of each event the guild
+	// tracks, keyed by event ID
+	TrackedEvents(guildID string) (map[string]meetup.Event, error)
+	// PutTrackedEvents replaces the events the guild tracks
+	PutTrackedEvents(guildID string, events map[string]meetup.Event) error
+
+	// SentReminders returns the reminder offsets already sent for each event
+	SentReminders(guildID string) (map[string][]string, error)
+	// PutSentReminders replaces the record of reminders sent
+	PutSentReminders(guildID string, sent map[string][]string) error
+
+	// PutHistory records value under key in the guild's history
+	PutHistory(guildID, key string, value []byte) error
+}
+
+// noGuildError is returned by stores for guilds they have no settings for
+func noGuildError(guildID string) error {
+	return fmt.Errorf("no settings bucket for guild %v", guildID)
+}
+
+// getGuildJSON unmarshals a JSON encoded setting into target. Missing keys
+// leave target untouched.
+func (b *Bot) getGuildJSON(guildID, key string, target interface{}) error {
+	v, err := b.Store.Setting(guildID, key)
+	if err != nil || len(v) == 0 {
+		return err
+	}
+	return json.Unmarshal(v, target)
+}
+
+// putGuildJSON stores value JSON encoded as a setting
+func (b *Bot) putGuildJSON(guildID, key string, value interface{}) error {
+	v, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return b.Store.PutSetting(guildID, key, v)
+}
+
+// putHistoryJSON records value JSON encoded in the guild's history
+func (b *Bot) putHistoryJSON(guildID, key string, value interface{}) error {
+	v, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return b.Store.PutHistory(guildID, key, v)
+}
+
+func (b *Bot) printGuild(guildID string) {
+	settings, err := b.Store.Settings(guildID)
+	if err != nil {
+		return
+	}
+
+	var keys []string
+	for k := range settings {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		fmt.Printf("key=%s, value=%s\n", k, settings[k])
+	}
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"github.com/boltdb/bolt"
+	"github.com/jaredkotoff/meetup-bot/meetup"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestMemoryStore(t *testing.T) {
+	t.Parallel()
+	testStore(t, func(t *testing.T) Store {
+		return newMemoryStore()
+	})
+}
+
+func TestBoltStore(t *testing.T) {
+	t.Parallel()
+	testStore(t, func(t *testing.T) Store {
+		return openTestDB(t, nil)
+	})
+}
+
+// openTestDB opens a migrated bolt db in a temporary directory, removed when
+// the test ends. setup, if given, writes to the db before it is migrated.
+func openTestDB(t *testing.T, setup func(tx *bolt.Tx) error) *boltStore {
+	dir, err := ioutil.TempDir("", "meetup-bot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	path := filepath.Join(dir, "settings.db")
+	db, err := bolt.Open(path, 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if setup != nil {
+		if err := db.Update(setup); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := migrate(db, path); err != nil {
+		t.Fatal(err)
+	}
+	return newBoltStore(db)
+}
+
+// testStore runs the same checks against every Store implementation.
+// newStore returns an empty store.
+func testStore(t *testing.T, newStore func(t *testing.T) Store) {
+	// ensure creates guildID, failing the test on error
+	ensure := func(t *testing.T, s Store, guildID string) bool {
+		restored, err := s.EnsureGuild(guildID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return restored
+	}
+	// setting reads a setting, failing the test on error
+	setting := func(t *testing.T, s Store, guildID, key string) string {
+		v, err := s.Setting(guildID, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(v)
+	}
+	event := func(id, name string) meetup.Event {
+		return meetup.Event{ID: id, Name: name, Status: "upcoming"}
+	}
+
+	t.Run("guilds", func(t *testing.T) {
+		s := newStore(t)
+		if guilds, err := s.Guilds(); err != nil || len(guilds) != 0 {
+			t.Fatalf("empty store has guilds %v, %v", guilds, err)
+		}
+		if ensure(t, s, "b") || ensure(t, s, "a") || ensure(t, s, "a") {
+			t.Error("EnsureGuild restored a guild that was never archived")
+		}
+		guilds, err := s.Guilds()
+		if err != nil {
+			t.Fatal(err)
+		}
+		sort.Strings(guilds)
+		if want := []string{"a", "b"}; !reflect.DeepEqual(guilds, want) {
+			t.Errorf("Guilds() = %v, want %v", guilds, want)
+		}
+	})
+
+	t.Run("settings", func(t *testing.T) {
+		s := newStore(t)
+		ensure(t, s, "guild")
+		if v := setting(t, s, "guild", "missing"); v != "" {
+			t.Errorf("missing setting = %q, want empty", v)
+		}
+		if err := s.PutSetting("guild", "a", []byte("1")); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.PutSetting("guild", "b", []byte("2")); err != nil {
+			t.Fatal(err)
+		}
+		if v := setting(t, s, "guild", "a"); v != "1" {
+			t.Errorf("a = %q, want 1", v)
+		}
+
+		// Values read out can't change what is stored
+		v, _ := s.Setting("guild", "a")
+		v[0] = 'x'
+		if v := setting(t, s, "guild", "a"); v != "1" {
+			t.Errorf("a = %q after changing a copy, want 1", v)
+		}
+
+		if err := s.DeleteSetting("guild", "b"); err != nil {
+			t.Fatal(err)
+		}
+		settings, err := s.Settings("guild")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := map[string][]byte{"a": []byte("1")}; !reflect.DeepEqual(settings, want) {
+			t.Errorf("Settings() = %q, want %q", settings, want)
+		}
+	})
+
+	t.Run("unknown guild", func(t *testing.T) {
+		s := newStore(t)
+		if _, err := s.Setting("nowhere", "a"); err == nil {
+			t.Error("Setting on an unknown guild returned no error")
+		}
+		if err := s.PutSetting("nowhere", "a", []byte("1")); err == nil {
+			t.Error("PutSetting on an unknown guild returned no error")
+		}
+		if err := s.PutTrackedEvents("nowhere", nil); err == nil {
+			t.Error("PutTrackedEvents on an unknown guild returned no error")
+		}
+	})
+
+	t.Run("tracked events and reminders", func(t *testing.T) {
+		s := newStore(t)
+		ensure(t, s, "guild")
+		err := s.PutTrackedEvents("guild", map[string]meetup.Event{"1": event("1", "One"), "2": event("2", "Two")})
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Putting replaces rather than merges
+		want := map[string]meetup.Event{"2": event("2", "Two changed"), "3": event("3", "Three")}
+		if err := s.PutTrackedEvents("guild", want); err != nil {
+			t.Fatal(err)
+		}
+		if tracked, err := s.TrackedEvents("guild"); err != nil || !reflect.DeepEqual(tracked, want) {
+			t.Errorf("TrackedEvents() = %v, %v, want %v", tracked, err, want)
+		}
+
+		if err := s.PutSentReminders("guild", map[string][]string{"1": {"24h0m0s"}}); err != nil {
+			t.Fatal(err)
+		}
+		sent := map[string][]string{"2": {"24h0m0s", "1h0m0s"}}
+		if err := s.PutSentReminders("guild", sent); err != nil {
+			t.Fatal(err)
+		}
+		if got, err := s.SentReminders("guild"); err != nil || !reflect.DeepEqual(got, sent) {
+			t.Errorf("SentReminders() = %v, %v, want %v", got, err, sent)
+		}
+		if err := s.PutHistory("guild", nextEventKey, []byte(`{"id":"2"}`)); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("archive and restore", func(t *testing.T) {
+		s := newStore(t)
+		ensure(t, s, "guild")
+		ensure(t, s, "other")
+		if err := s.PutSetting("guild", groupsKey, []byte(`["golang-chicago"]`)); err != nil {
+			t.Fatal(err)
+		}
+		events := map[string]meetup.Event{"1": event("1", "One")}
+		if err := s.PutTrackedEvents("guild", events); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.ArchiveGuild("guild"); err != nil {
+			t.Fatal(err)
+		}
+		if guilds, err := s.Guilds(); err != nil || !reflect.DeepEqual(guilds, []string{"other"}) {
+			t.Errorf("Guilds() after archiving = %v, %v, want [other]", guilds, err)
+		}
+		if _, err := s.Setting("guild", groupsKey); err == nil {
+			t.Error("archived guild's settings are still readable")
+		}
+		// Archiving a guild that isn't there changes nothing
+		if err := s.ArchiveGuild("guild"); err != nil {
+			t.Fatal(err)
+		}
+
+		if !ensure(t, s, "guild") {
+			t.Error("EnsureGuild didn't restore the archived guild")
+		}
+		if v := setting(t, s, "guild", groupsKey); v != `["golang-chicago"]` {
+			t.Errorf("restored groups = %q", v)
+		}
+		if tracked, err := s.TrackedEvents("guild"); err != nil || !reflect.DeepEqual(tracked, events) {
+			t.Errorf("restored events = %v, %v, want %v", tracked, err, events)
+		}
+		if ensure(t, s, "guild") {
+			t.Error("EnsureGuild restored the guild twice")
+		}
+
+		// A second archive replaces the first
+		if err := s.PutSetting("guild", groupsKey, []byte(`["chicago-rust"]`)); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.ArchiveGuild("guild"); err != nil {
+			t.Fatal(err)
+		}
+		ensure(t, s, "guild")
+		if v := setting(t, s, "guild", groupsKey); v != `["chicago-rust"]` {
+			t.Errorf("groups restored from the second archive = %q", v)
+		}
+	})
+
+	t.Run("purge", func(t *testing.T) {
+		s := newStore(t)
+		ensure(t, s, "guild")
+		if err := s.PutSetting("guild", groupsKey, []byte(`["golang-chicago"]`)); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.ArchiveGuild("guild"); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.PurgeGuild("guild"); err != nil {
+			t.Fatal(err)
+		}
+		if ensure(t, s, "guild") {
+			t.Error("EnsureGuild restored a purged guild")
+		}
+		if v := setting(t, s, "guild", groupsKey); v != "" {
+			t.Errorf("purged guild still has groups %q", v)
+		}
+
+		if err := s.PurgeGuild("guild"); err != nil {
+			t.Fatal(err)
+		}
+		if guilds, err := s.Guilds(); err != nil || len(guilds) != 0 {
+			t.Errorf("Guilds() after purging = %v, %v", guilds, err)
+		}
+	})
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/bwmarrin/discordgo"
+	"github.com/jaredkotoff/meetup-bot/meetup"
+	"io"
+	"log"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// templatesKey holds a guild's JSON map of template name to template text
+const templatesKey = "templates"
+
+// maxTemplateOutput is the most a template may write. Long messages are split
+// when sent, but no message should need more than a couple of parts.
+const maxTemplateOutput = 2 * discordMessageLimit
+
+// summaryTemplate is the event summary the default templates share
+const summaryTemplate = "{{if .ShowGroup}}[{{.Group.Name}}] {{end}}`{{.Event.Name}}` - {{.Time}}" +
+	"{{if .Venue.Name}}\nAt: {{venue .Venue}}{{end}}\n{{.Event.Link}}"
+
+// defaultTemplates are used for any message a guild hasn't overridden
+var defaultTemplates = map[string]string{
+	"next":     "Next event: " + summaryTemplate + "{{with .Description}}\n\n{{truncate .}}{{end}}",
+	"announce": "New event posted: " + summaryTemplate,
+	"reminder": "Starting in {{.Until}}: " + summaryTemplate,
+	"listing": "**{{.Index}}.** " + summaryTemplate + "\n{{.Event.YesRSVPCount}} going" +
+		"{{if .Event.WaitlistCount}}, {{.Event.WaitlistCount}} on the waitlist{{end}}",
+}
+
+// templateFuncs are the helper functions available to every template
+var templateFuncs = template.FuncMap{
+	"venue":    venueString,
+	"relative": relativeTime,
+	"truncate": truncate,
+	"markdown": htmlToMarkdown,
+	"upper":    strings.ToUpper,
+	"lower":    strings.ToLower,
+}
+
+// sampleEvent is what templates are test executed against before saving
+var sampleEvent = meetup.Event{
+	ID:            "123456789",
+	Name:          "Monthly Meetup",
+	Status:        "upcoming",
+	Time:          1475366400000,
+	Updated:       1470000000000,
+	UTCOffset:     -14400000,
+	WaitlistCount: 2,
+	YesRSVPCount:  40,
+	Venue: meetup.Venue{
+		Name:     "Community Center",
+		Address1: "123 Main St",
+		City:     "Springfield",
+		State:    "IL",
+		Zip:      "62701",
+	},
+	Group: meetup.EventGroup{
+		Name:    "Springfield Gophers",
+		URLName: "springfield-gophers",
+	},
+	Link:        "https://www.meetup.com/springfield-gophers/events/123456789/",
+	Description: "<p>Talks, pizza and plenty of gophers.</p>",
+	Visibility:  "public",
+}
+
+// templateData is what message templates are executed against
+type templateData struct {
+	Event meetup.Event
+	Venue meetup.Venue
+	// Time is the event's formatted start time
+	Time string
+	// Description is the event's description converted to Discord markdown
+	Description string
+	// Relative is when the event starts relative to now, e.g. "in 3 days"
+	Relative string
+	// Until is how long until the event starts, set for reminders
+	Until string
+	// Index is the event's position, set for listings
+	Index int
+	// Group is the group hosting the event
+	Group meetup.EventGroup
+	// ShowGroup is set when the guild follows several groups, so events
+	// should be labeled with their group
+	ShowGroup bool
+}
+
+func init() {
+	router.Register(&Command{
+		Name:        "template",
+		Description: "Shows or changes how the bot's messages are formatted",
+		Usage:       "[name] [template|reset]",
+		Args:        []Arg{{Name: "name", Optional: true}, {Name: "template", Optional: true, Rest: true, Raw: true}},
+		Permission:  discordgo.PermissionManageServer,
+		Examples:    []string{"", "next", "next Up next: {{.Event.Name}} {{.Event.Link}}", "next reset"},
+		Handler:     setTemplate,
+	})
+}
+
+// eventData builds the template data for an event
+func eventData(event meetup.Event, ts timeSettings) templateData {
+	return templateData{
+		Event:       event,
+		Venue:       event.Venue,
+		Group:       event.Group,
+		Time:        ts.format(event),
+		Description: htmlToMarkdown(event.Description),
+		Relative:    relativeTime(event.Time),
+	}
+}
+
+// validateTemplate checks text parses and executes against sampleEvent
+func validateTemplate(name, text string) error {
+	data := eventData(sampleEvent, timeSettings{Layout: defaultTimeFormat})
+	data.Until = "1 day"
+	data.Index = 1
+	data.ShowGroup = true
+	msg, err := executeTemplate(name, text, data)
+	if err == nil && strings.TrimSpace(msg) == "" {
+		err = fmt.Errorf("template produces an empty message")
+	}
+	return err
+}
+
+// executeTemplate parses and runs a template against data. Templates that
+// write more than maxTemplateOutput bytes are stopped with an error.
+func executeTemplate(name, text string, data templateData) (string, error) {
+	tmpl, err := template.New(name).Funcs(templateFuncs).Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	err = tmpl.Execute(&limitedWriter{w: &buf, n: maxTemplateOutput}, data)
+	return buf.String(), err
+}
+
+// limitedWriter fails writes once more than n bytes have been written
+type limitedWriter struct {
+	w io.Writer
+	n int
+}
+
+func (lw *limitedWriter) Write(p []byte) (int, error) {
+	if len(p) > lw.n {
+		return 0, fmt.Errorf("template produces more than %v characters", maxTemplateOutput)
+	}
+	lw.n -= len(p)
+	return lw.w.Write(p)
+}
+
+// renderEvent formats data with the guild's template for name. A stored
+// template that fails falls back to the default.
+func (b *Bot) renderEvent(guildID, name string, data templateData) string {
+	overrides := make(map[string]string)
+	if err := b.getGuildJSON(guildID, templatesKey, &overrides); err != nil {
+		log.Printf("Error getting templates: %s\n", err.Error())
+	}
+	if text, ok := overrides[name]; ok {
+		msg, err := executeTemplate(name, text, data)
+		if err == nil {
+			return msg
+		}
+		log.Printf("Error rendering %v template for guild %v: %s\n", name, guildID, err.Error())
+	}
+
+	msg, err := executeTemplate(name, defaultTemplates[name], data)
+	if err != nil {
+		log.Printf("Error rendering default %v template: %s\n", name, err.Error())
+	}
+	return msg
+}
+
+// Shows or changes how the bot's messages are formatted
+func setTemplate(ctx *Context) error {
+	var names []string
+	for name := range defaultTemplates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	name := strings.ToLower(ctx.Arg("name"))
+	if name == "" {
+		ctx.Reply(fmt.Sprintf("Templates: %v\nFields: `.Event`, `.Venue`, `.Time`, `.Relative`, `.Group`, `.Until` (reminders), `.Index` (listings)",
+			strings.Join(names, ", ")))
+		return nil
+	}
+	if _, ok := defaultTemplates[name]; !ok {
+		ctx.Reply(fmt.Sprintf("Unknown template %v, pick from: %v", name, strings.Join(names, ", ")))
+		return nil
+	}
+
+	channel, err := getChannel(ctx.Session, ctx.Message.ChannelID)
+	if err != nil {
+		return err
+	}
+	overrides := make(map[string]string)
+	if err := ctx.Bot.getGuildJSON(channel.GuildID, templatesKey, &overrides); err != nil {
+		return err
+	}
+
+	if ctx.Arg("template") == "" {
+		text, ok := overrides[name]
+		if !ok {
+			text = defaultTemplates[name]
+		}
+		ctx.Reply(fmt.Sprintf("The %v template is:\n```\n%v\n```", name, text))
+		return nil
+	}
+
+	// The template is taken as typed so quotes and spacing survive, and it
+	// may be wrapped in a code block
+	text := strings.TrimSuffix(strings.TrimPrefix(ctx.Arg("template"), "```"), "```")
+	text = strings.Trim(text, "\n")
+
+	if strings.ToLower(text) == "reset" {
+		delete(overrides, name)
+		if err := ctx.Bot.putGuildJSON(channel.GuildID, templatesKey, overrides); err != nil {
+			return err
+		}
+		ctx.Reply(fmt.Sprintf("The %v template is back to the default", name))
+		return nil
+	}
+
+	if err := validateTemplate(name, text); err != nil {
+		ctx.Reply(fmt.Sprintf("Invalid template: %s", err.Error()))
+		return nil
+	}
+	overrides[name] = text
+	if err := ctx.Bot.putGuildJSON(channel.GuildID, templatesKey, overrides); err != nil {
+		return err
+	}
+	ctx.Reply(fmt.Sprintf("The %v template is updated", name))
+	return nil
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestTemplate(t *testing.T) {
+	t.Parallel()
+	runCommandTests(t, []commandTest{
+		{
+			name:     "list",
+			commands: []string{"!template"},
+			want: []sentMessage{reply("Templates: announce, listing, next, reminder\n" +
+				"Fields: `.Event`, `.Venue`, `.Time`, `.Relative`, `.Group`, `.Until` (reminders), `.Index` (listings)")},
+		},
+		{
+			name:     "unknown",
+			commands: []string{"!template digest"},
+			want:     []sentMessage{reply("Unknown template digest, pick from: announce, listing, next, reminder")},
+		},
+		{
+			name:     "show default",
+			commands: []string{"!template next"},
+			want:     []sentMessage{reply("The next template is:\n```\n" + defaultTemplates["next"] + "\n```")},
+		},
+		{
+			name:     "set",
+			commands: []string{`!template next Up next: "{{.Event.Name}}"`},
+			want:     []sentMessage{reply("The next template is updated")},
+		},
+		{
+			name:     "show set",
+			commands: []string{`!template NEXT Up next:  "{{.Event.Name}}"`, "!template next"},
+			want:     []sentMessage{reply("The next template is:\n```\nUp next:  \"{{.Event.Name}}\"\n```")},
+		},
+		{
+			name:     "quoted name",
+			commands: []string{`!template "next" "{{.Event.Name}}" next`, "!template next"},
+			want:     []sentMessage{reply("The next template is:\n```\n\"{{.Event.Name}}\" next\n```")},
+		},
+		{
+			name:     "set in a code block",
+			commands: []string{"!template next ```\n{{.Event.Name}}\n{{.Time}}\n```", "!template next"},
+			want:     []sentMessage{reply("The next template is:\n```\n{{.Event.Name}}\n{{.Time}}\n```")},
+		},
+		{
+			name:     "reset",
+			commands: []string{"!template next {{.Event.Name}}", "!template next reset", "!template next"},
+			want:     []sentMessage{reply("The next template is:\n```\n" + defaultTemplates["next"] + "\n```")},
+		},
+		{
+			name:     "parse error",
+			commands: []string{"!template next {{if}}"},
+			want:     []sentMessage{reply("Invalid template: template: next:1: missing value for if")},
+		},
+		{
+			name:     "empty output",
+			commands: []string{"!template next {{/* nothing */}}"},
+			want:     []sentMessage{reply("Invalid template: template produces an empty message")},
+		},
+		{
+			name:     "output too long",
+			commands: []string{`!template next {{printf "%5000d" 1}}`},
+			want:     []sentMessage{reply("Invalid template: template produces more than 4000 characters")},
+		},
+		{
+			name:     "denied",
+			author:   testUserID,
+			commands: []string{"!template next"},
+			want:     denied("template"),
+		},
+	})
+}
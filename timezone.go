@@ -0,0 +1,182 @@
+package main
+
+import (
+	"fmt"
+	"github.com/bwmarrin/discordgo"
+	"github.com/jaredkotoff/meetup-bot/meetup"
+	"log"
+	"strings"
+	"time"
+)
+
+const (
+	// timezoneKey holds a guild's IANA timezone name, e.g. "America/Chicago"
+	timezoneKey = "timezone"
+	// timeFormatKey holds a guild's Go time layout
+	timeFormatKey = "timeformat"
+	// defaultTimeFormat is used when a guild hasn't set a time format
+	defaultTimeFormat = "Mon Jan 2 3:04 PM MST"
+)
+
+// timeSettings controls how a guild's event times are shown
+type timeSettings struct {
+	// Location to show times in. Nil shows each event in its own UTC offset.
+	Location *time.Location
+	Layout   string
+}
+
+func init() {
+	router.Register(&Command{
+		Name:        "timezone",
+		Description: "Sets the timezone event times are shown in",
+		Usage:       "[zone|reset]",
+		Args:        []Arg{{Name: "zone", Optional: true}},
+		Permission:  discordgo.PermissionManageServer,
+		Examples:    []string{"", "America/Chicago", "reset"},
+		Handler:     setTimezone,
+	})
+	router.Register(&Command{
+		Name:        "timeformat",
+		Description: "Sets how event times are formatted",
+		Usage:       "[layout|reset]",
+		Args:        []Arg{{Name: "layout", Optional: true, Rest: true}},
+		Permission:  discordgo.PermissionManageServer,
+		Examples:    []string{"", "Mon Jan 2 15:04 MST", "reset"},
+		Handler:     setTimeFormat,
+	})
+}
+
+// getTimeSettings loads a guild's time settings, falling back to the defaults
+// for anything unset or invalid
+func (b *Bot) getTimeSettings(guildID string) timeSettings {
+	ts := timeSettings{Layout: defaultTimeFormat}
+
+	zone, err := b.Store.Setting(guildID, timezoneKey)
+	if err == nil && len(zone) > 0 {
+		ts.Location, err = time.LoadLocation(string(zone))
+	}
+	if err != nil {
+		log.Printf("Error getting timezone for guild %v: %s\n", guildID, err.Error())
+	}
+
+	layout, err := b.Store.Setting(guildID, timeFormatKey)
+	if err != nil {
+		log.Printf("Error getting time format for guild %v: %s\n", guildID, err.Error())
+	}
+	if len(layout) > 0 {
+		ts.Layout = string(layout)
+	}
+	return ts
+}
+
+// eventTime returns when an event starts in the zone it should be shown in
+func (ts timeSettings) eventTime(event meetup.Event) time.Time {
+	start := msToTime(event.Time)
+	if ts.Location != nil {
+		return start.In(ts.Location)
+	}
+	// Meetup sends the offset in ms; name the zone after it so layouts with
+	// MST show e.g. UTC-04:00 rather than a blank
+	offset := int(event.UTCOffset / 1000)
+	return start.In(time.FixedZone(offsetName(offset), offset))
+}
+
+// format returns an event's start time formatted for the guild
+func (ts timeSettings) format(event meetup.Event) string {
+	return ts.eventTime(event).Format(ts.Layout)
+}
+
+// offsetName names a fixed zone by its offset in seconds, e.g. "UTC-04:00"
+func offsetName(offset int) string {
+	sign := "+"
+	if offset < 0 {
+		sign = "-"
+		offset = -offset
+	}
+	return fmt.Sprintf("UTC%v%02d:%02d", sign, offset/3600, offset%3600/60)
+}
+
+// relativeTime describes when ms since epoch is relative to now, e.g.
+// "in 3 days" or "2 hours ago"
+func relativeTime(ms int64) string {
+	d := msToTime(ms).Sub(time.Now())
+	if d < 0 {
+		return roundOffset(-d) + " ago"
+	}
+	return "in " + roundOffset(d)
+}
+
+// Sets or shows the timezone event times are shown in
+func setTimezone(ctx *Context) error {
+	channel, err := getChannel(ctx.Session, ctx.Message.ChannelID)
+	if err != nil {
+		return err
+	}
+
+	zone := ctx.Arg("zone")
+	switch {
+	case zone == "":
+		current, err := ctx.Bot.Store.Setting(channel.GuildID, timezoneKey)
+		if err != nil {
+			return err
+		}
+		if len(current) == 0 {
+			ctx.Reply("Event times are shown in each event's own timezone")
+		} else {
+			ctx.Reply(fmt.Sprintf("Event times are shown in %v", string(current)))
+		}
+		return nil
+	case strings.ToLower(zone) == "reset":
+		if err := ctx.Bot.Store.DeleteSetting(channel.GuildID, timezoneKey); err != nil {
+			return err
+		}
+		ctx.Reply("Event times will be shown in each event's own timezone")
+		return nil
+	}
+
+	loc, err := time.LoadLocation(zone)
+	if err != nil || zone == "Local" {
+		ctx.Reply(fmt.Sprintf("Unknown timezone %v, use a name like America/New_York", zone))
+		return nil
+	}
+	if err := ctx.Bot.Store.PutSetting(channel.GuildID, timezoneKey, []byte(loc.String())); err != nil {
+		return err
+	}
+	ctx.Reply(fmt.Sprintf("Event times will be shown in %v", loc.String()))
+	return nil
+}
+
+// Sets or shows how event times are formatted
+func setTimeFormat(ctx *Context) error {
+	channel, err := getChannel(ctx.Session, ctx.Message.ChannelID)
+	if err != nil {
+		return err
+	}
+
+	layout := ctx.Arg("layout")
+	sample := timeSettings{Layout: layout}
+	switch {
+	case layout == "":
+		sample = ctx.Bot.getTimeSettings(channel.GuildID)
+		ctx.Reply(fmt.Sprintf("Event times look like `%v` (layout `%v`)",
+			sample.format(sampleEvent), sample.Layout))
+		return nil
+	case strings.ToLower(layout) == "reset":
+		if err := ctx.Bot.Store.DeleteSetting(channel.GuildID, timeFormatKey); err != nil {
+			return err
+		}
+		ctx.Reply("Event times will use the default format")
+		return nil
+	}
+
+	// A layout with no reference time elements formats to itself
+	if sample.format(sampleEvent) == layout {
+		ctx.Reply("Invalid layout, write how `Mon Jan 2 3:04 PM MST 2006` should look, e.g. `Jan 2 15:04`")
+		return nil
+	}
+	if err := ctx.Bot.Store.PutSetting(channel.GuildID, timeFormatKey, []byte(layout)); err != nil {
+		return err
+	}
+	ctx.Reply(fmt.Sprintf("Event times will look like `%v`", sample.format(sampleEvent)))
+	return nil
+}
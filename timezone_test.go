@@ -0,0 +1,85 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestTimezone(t *testing.T) {
+	t.Parallel()
+	runCommandTests(t, []commandTest{
+		{
+			name:     "default",
+			commands: []string{"!timezone"},
+			want:     []sentMessage{reply("Event times are shown in each event's own timezone")},
+		},
+		{
+			name:     "set",
+			commands: []string{"!timezone America/Chicago"},
+			want:     []sentMessage{reply("Event times will be shown in America/Chicago")},
+		},
+		{
+			name:     "show set",
+			commands: []string{"!timezone America/Chicago", "!timezone"},
+			want:     []sentMessage{reply("Event times are shown in America/Chicago")},
+		},
+		{
+			name:     "reset",
+			commands: []string{"!timezone America/Chicago", "!timezone reset", "!timezone"},
+			want:     []sentMessage{reply("Event times are shown in each event's own timezone")},
+		},
+		{
+			name:     "unknown",
+			commands: []string{"!timezone Mars/Olympus_Mons"},
+			want:     []sentMessage{reply("Unknown timezone Mars/Olympus_Mons, use a name like America/New_York")},
+		},
+		{
+			name:     "server's local zone",
+			commands: []string{"!timezone Local"},
+			want:     []sentMessage{reply("Unknown timezone Local, use a name like America/New_York")},
+		},
+		{
+			name:     "denied",
+			author:   testUserID,
+			commands: []string{"!timezone UTC"},
+			want:     denied("timezone"),
+		},
+	})
+}
+
+func TestTimeFormat(t *testing.T) {
+	t.Parallel()
+	runCommandTests(t, []commandTest{
+		{
+			name:     "default",
+			commands: []string{"!timeformat"},
+			want:     []sentMessage{reply("Event times look like `Sat Oct 1 8:00 PM UTC-04:00` (layout `Mon Jan 2 3:04 PM MST`)")},
+		},
+		{
+			name:     "set",
+			commands: []string{"!timeformat Jan 2 15:04"},
+			want:     []sentMessage{reply("Event times will look like `Oct 1 20:00`")},
+		},
+		{
+			name:     "show set in timezone",
+			commands: []string{"!timeformat Jan 2 15:04 MST", "!timezone UTC", "!timeformat"},
+			want:     []sentMessage{reply("Event times look like `Oct 2 00:00 UTC` (layout `Jan 2 15:04 MST`)")},
+		},
+		{
+			name:     "reset",
+			commands: []string{"!timeformat Jan 2", "!timeformat reset"},
+			want:     []sentMessage{reply("Event times will use the default format")},
+		},
+		{
+			name:     "no time elements",
+			commands: []string{"!timeformat whenever"},
+			want: []sentMessage{reply("Invalid layout, write how `Mon Jan 2 3:04 PM MST 2006` should look, " +
+				"e.g. `Jan 2 15:04`")},
+		},
+		{
+			name:     "denied",
+			author:   testUserID,
+			commands: []string{"!timeformat reset"},
+			want:     denied("timeformat"),
+		},
+	})
+}